@@ -5,7 +5,6 @@
 	"context"
 	"encoding/json"
 	"fmt"
-	"strconv"
 	"sync"
 	"time"
 
@@ -128,14 +127,24 @@ func (m *Manager) onTrigger(_ string, obj *catalog.ClusterRepo) (*catalog.Cluste
 	return obj, nil
 }
 
+// refreshInterval returns the interval between refreshes of the system charts. It is at least a second,
+// since a ticker can't be created for a zero or negative interval.
+func refreshInterval() time.Duration {
+	seconds := settings.SystemFeatureChartRefreshSeconds.GetSeconds()
+	if seconds < 1 {
+		seconds = 1
+	}
+	return time.Duration(seconds) * time.Second
+}
+
 func (m *Manager) runSync() {
-	t := time.NewTicker(getIntervalOrDefault(settings.SystemFeatureChartRefreshSeconds.Get()))
+	t := time.NewTicker(refreshInterval())
 	defer t.Stop()
 
 	for {
 		select {
 		case <-m.refreshIntervalChange:
-			t = time.NewTicker(getIntervalOrDefault(settings.SystemFeatureChartRefreshSeconds.Get()))
+			t = time.NewTicker(refreshInterval())
 		case <-m.ctx.Done():
 			return
 		case <-m.trigger:
@@ -157,14 +166,6 @@ func (m *Manager) runSync() {
 	}
 }
 
-func getIntervalOrDefault(interval string) time.Duration {
-	i, err := strconv.Atoi(interval)
-	if err != nil {
-		return 900 * time.Second
-	}
-	return time.Duration(i) * time.Second
-}
-
 func (m *Manager) installCharts(charts map[desiredKey]map[string]interface{}, forceAdopt bool) error {
 	var errs []error
 	for key, values := range charts {
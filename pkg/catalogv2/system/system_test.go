@@ -0,0 +1,33 @@
+package system
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefreshInterval(t *testing.T) {
+	inputs := map[string]time.Duration{
+		"0":       time.Second,
+		"-5":      15 * time.Minute,
+		"1":       time.Second,
+		"3600":    time.Hour,
+		"garbage": 15 * time.Minute,
+	}
+	a := assert.New(t)
+	defer func(value string) {
+		_ = settings.SystemFeatureChartRefreshSeconds.Set(value)
+	}(settings.SystemFeatureChartRefreshSeconds.Get())
+
+	for key, value := range inputs {
+		if err := settings.SystemFeatureChartRefreshSeconds.Set(key); err != nil {
+			t.Errorf("Encountered error while setting temp value: %v\n", err)
+		}
+		result := refreshInterval()
+		a.Equal(value, result, fmt.Sprintf("Expected value [%v] for key [%s]. Got value [%v]", value, key, result))
+		a.NotPanics(func() { time.NewTicker(result).Stop() })
+	}
+}
@@ -11,8 +11,7 @@
 
 func Test_injectDefaultRegistry(t *testing.T) {
 	testRegistry := "test.registry.com"
-	err := settings.SystemDefaultRegistry.Set(testRegistry)
-	assert.Nil(t, err, "failed to set system default registry settings")
+	t.Setenv(settings.GetEnvKey(settings.SystemDefaultRegistry.Name), testRegistry)
 
 	testCases := []struct {
 		app  *v3.App
@@ -140,7 +140,7 @@ type args struct {
 			}
 
 			//act
-			err := settings.ServerURL.Set("localhost")
+			err := settings.ServerURL.Set("https://localhost")
 			a.Nil(err)
 
 			serviceAccount, err := handler.serviceAccountCache.Get(tt.args.namespaceName, tt.args.secretName)
@@ -175,7 +175,7 @@ type args struct {
 				a.True(machine.GetLabels()[rke2.ControlPlaneRoleLabel] == "true")
 				a.True(machine.GetLabels()[rke2.EtcdRoleLabel] == "true")
 				a.True(machine.GetLabels()[rke2.WorkerRoleLabel] == "true")
-				a.Contains(data, "CATTLE_SERVER=localhost")
+				a.Contains(data, "CATTLE_SERVER=https://localhost")
 				a.Contains(data, "CATTLE_ROLE_NONE=true")
 
 			case rke2.WindowsMachineOS:
@@ -185,7 +185,7 @@ type args struct {
 				a.True(machine.GetLabels()[rke2.ControlPlaneRoleLabel] == "false")
 				a.True(machine.GetLabels()[rke2.EtcdRoleLabel] == "false")
 				a.True(machine.GetLabels()[rke2.WorkerRoleLabel] == "true")
-				a.Contains(data, "$env:CATTLE_SERVER=\"localhost\"")
+				a.Contains(data, "$env:CATTLE_SERVER=\"https://localhost\"")
 				a.Contains(data, "CATTLE_ROLE_NONE=\"true\"")
 				a.Contains(data, "$env:CSI_PROXY_URL")
 				a.Contains(data, "$env:CSI_PROXY_VERSION")
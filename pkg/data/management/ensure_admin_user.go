@@ -3,6 +3,7 @@
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/docker/docker/pkg/reexec"
 	"github.com/pkg/errors"
@@ -10,6 +11,8 @@
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
 	"github.com/urfave/cli"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
@@ -22,7 +25,32 @@ func ensureDefaultAdmin() {
 	app := cli.NewApp()
 	app.Description = "Ensure an available default admin user"
 
+	var adminLabel, adminUsername, adminDisplayName string
+	app.Flags = []cli.Flag{
+		adminLabelFlag(&adminLabel),
+		cli.StringFlag{
+			Name:        "admin-username",
+			Usage:       "Username of the default admin user, used to find it and when creating it",
+			Value:       "admin",
+			Destination: &adminUsername,
+		},
+		cli.StringFlag{
+			Name:        "admin-display-name",
+			Usage:       "Display name given to the default admin user when creating it",
+			Value:       "Default Admin",
+			Destination: &adminDisplayName,
+		},
+	}
+
 	app.Action = func(c *cli.Context) error {
+		label, err := parseAdminLabel(adminLabel)
+		if err != nil {
+			return err
+		}
+		if err := validateAdminUsername(adminUsername); err != nil {
+			return err
+		}
+
 		kubeConfigPath := os.ExpandEnv("$HOME/.kube/config")
 		if _, err := os.Stat(kubeConfigPath); err != nil {
 			kubeConfigPath = ""
@@ -45,7 +73,7 @@ func ensureDefaultAdmin() {
 
 		var admins []v3.User
 		for _, u := range users.Items {
-			if u.Username == "admin" {
+			if u.Username == adminUsername {
 				admins = append(admins, u)
 			}
 		}
@@ -56,14 +84,14 @@ func ensureDefaultAdmin() {
 			for _, u := range admins {
 				adminNames = append(adminNames, u.Name)
 			}
-			return errors.Errorf("%v users were found with the name \"admin\". They are %v. Can only reset the default admin password when there is exactly one user with this label",
-				count, adminNames)
+			return errors.Errorf("%v users were found with the name %q. They are %v. Can only reset the default admin password when there is exactly one user with this label",
+				count, adminUsername, adminNames)
 		} else if count == 1 {
 			admin := admins[0]
 			fmt.Fprintf(os.Stdout, "Found existing default admin user (%v)\n", admin.Name)
 
 			enabledChanged := ensureAdminIsEnabled(&admin)
-			labelingChanged := ensureAdminIsLabeled(&admin)
+			labelingChanged := ensureAdminIsLabeled(&admin, label)
 
 			if enabledChanged || labelingChanged {
 				_, err = client.Users("").Update(&admin)
@@ -71,13 +99,13 @@ func ensureDefaultAdmin() {
 			if err != nil {
 				return errors.Errorf("Error updating user. %v", err)
 			}
-			err = ensureAdminIsAdmin(client, admin)
+			err = ensureAdminIsAdmin(client, admin, label)
 			if err != nil {
 				return errors.Errorf("Couldn't make existing \"admin\" an actual admin. %v", err)
 			}
 
 		} else {
-			err = createNewAdmin(client, length)
+			err = createNewAdmin(client, length, label, adminUsername, adminDisplayName)
 			if err != nil {
 				return errors.Errorf("Couldn't create a new admin. %v", err)
 			}
@@ -93,7 +121,18 @@ func ensureDefaultAdmin() {
 	}
 }
 
-func createNewAdmin(client v3.Interface, length int) error {
+// validateAdminUsername checks that the given username is a non-empty DNS-safe name.
+func validateAdminUsername(username string) error {
+	if username == "" {
+		return errors.New("--admin-username must not be empty")
+	}
+	if errs := validation.IsDNS1123Subdomain(username); len(errs) > 0 {
+		return errors.Errorf("invalid --admin-username %v: %v", username, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func createNewAdmin(client v3.Interface, length int, label labels.Set, username, displayName string) error {
 	pass := generatePassword(length)
 	hashedPass, err := user.HashPasswordString(string(pass))
 	if err != nil {
@@ -103,10 +142,10 @@ func createNewAdmin(client v3.Interface, length int) error {
 	admin, err := client.Users("").Create(&v3.User{
 		ObjectMeta: v1.ObjectMeta{
 			GenerateName: "user-",
-			Labels:       defaultAdminLabel,
+			Labels:       label,
 		},
-		DisplayName:        "Default Admin",
-		Username:           "admin",
+		DisplayName:        displayName,
+		Username:           username,
 		Password:           string(hashedPass),
 		MustChangePassword: false,
 	})
@@ -115,7 +154,7 @@ func createNewAdmin(client v3.Interface, length int) error {
 		return err
 	}
 
-	addAdminRoleToUser(client, *admin)
+	addAdminRoleToUser(client, *admin, label)
 
 	fmt.Fprintf(os.Stdout, "New default admin user (%v):\n", admin.Name)
 	fmt.Fprintf(os.Stdout, "New password for default admin user (%v):\n%s\n", admin.Name, pass)
@@ -134,7 +173,7 @@ func ensureAdminIsEnabled(admin *v3.User) bool {
 	return true
 }
 
-func ensureAdminIsAdmin(client v3.Interface, admin v3.User) error {
+func ensureAdminIsAdmin(client v3.Interface, admin v3.User, label labels.Set) error {
 	bindings, err := client.GlobalRoleBindings("").List(v1.ListOptions{})
 	if err != nil {
 		return err
@@ -148,19 +187,21 @@ func ensureAdminIsAdmin(client v3.Interface, admin v3.User) error {
 	}
 
 	fmt.Fprintf(os.Stdout, "Giving existing default admin user (%v) admin permissions\n", admin.Name)
-	return addAdminRoleToUser(client, admin)
+	return addAdminRoleToUser(client, admin, label)
 }
 
-func ensureAdminIsLabeled(admin *v3.User) bool {
-	changed := true
-	if current, exists := admin.ObjectMeta.Labels[defaultAdminLabelKey]; exists {
-		changed = current != defaultAdminLabelValue
-	}
+func ensureAdminIsLabeled(admin *v3.User, label labels.Set) bool {
+	changed := !label.AsSelector().Matches(labels.Set(admin.ObjectMeta.Labels))
 
 	if changed {
 		fmt.Fprintf(os.Stdout, "Labeling existing default admin user (%v) as admin\n", admin.Name)
 
-		admin.ObjectMeta.Labels[defaultAdminLabelKey] = defaultAdminLabelValue
+		if admin.ObjectMeta.Labels == nil {
+			admin.ObjectMeta.Labels = map[string]string{}
+		}
+		for key, value := range label {
+			admin.ObjectMeta.Labels[key] = value
+		}
 	} else {
 		fmt.Fprintf(os.Stdout, "Existing default admin user (%v) already labeled as admin\n", admin.Name)
 	}
@@ -168,12 +209,13 @@ func ensureAdminIsLabeled(admin *v3.User) bool {
 	return changed
 }
 
-func addAdminRoleToUser(client v3.Interface, admin v3.User) error {
+// addAdminRoleToUser binds the admin to the admin global role with a binding that carries the given label.
+func addAdminRoleToUser(client v3.GlobalRoleBindingsGetter, admin v3.User, label labels.Set) error {
 	_, err := client.GlobalRoleBindings("").Create(
 		&v3.GlobalRoleBinding{
 			ObjectMeta: v1.ObjectMeta{
 				GenerateName: "globalrolebinding-",
-				Labels:       defaultAdminLabel,
+				Labels:       label,
 			},
 			UserName:       admin.Name,
 			GlobalRoleName: "admin",
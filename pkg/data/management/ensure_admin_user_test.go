@@ -0,0 +1,56 @@
+package management
+
+import (
+	"testing"
+
+	apiv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3/fakes"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestValidateAdminUsername(t *testing.T) {
+	tests := map[string]bool{
+		"admin":          true,
+		"rancher-admin":  true,
+		"admin.example":  true,
+		"":               false,
+		"Admin":          false,
+		"admin user":     false,
+		"-admin":         false,
+		"admin_username": false,
+	}
+	for username, valid := range tests {
+		err := validateAdminUsername(username)
+		assert.Equal(t, valid, err == nil, "unexpected validation result for username %q: %v", username, err)
+	}
+}
+
+type fakeGlobalRoleBindingsGetter struct {
+	bindings v3.GlobalRoleBindingInterface
+}
+
+func (f fakeGlobalRoleBindingsGetter) GlobalRoleBindings(string) v3.GlobalRoleBindingInterface {
+	return f.bindings
+}
+
+func TestAddAdminRoleToUser(t *testing.T) {
+	var created []*apiv3.GlobalRoleBinding
+	client := fakeGlobalRoleBindingsGetter{bindings: &fakes.GlobalRoleBindingInterfaceMock{
+		CreateFunc: func(binding *apiv3.GlobalRoleBinding) (*apiv3.GlobalRoleBinding, error) {
+			created = append(created, binding)
+			return binding, nil
+		},
+	}}
+	label := labels.Set{"example.com/admin": "true"}
+
+	err := addAdminRoleToUser(client, apiv3.User{ObjectMeta: v1.ObjectMeta{Name: "user-abc"}}, label)
+	assert.NoError(t, err)
+	if assert.Len(t, created, 1) {
+		assert.Equal(t, map[string]string(label), created[0].Labels)
+		assert.Equal(t, "user-abc", created[0].UserName)
+		assert.Equal(t, "admin", created[0].GlobalRoleName)
+	}
+}
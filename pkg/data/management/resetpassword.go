@@ -1,19 +1,37 @@
 package management
 
 import (
+	"bytes"
+	"context"
 	"crypto/rand"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"math"
+	"math/big"
 	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/docker/docker/pkg/reexec"
 	"github.com/pkg/errors"
-	"github.com/rancher/rancher/pkg/auth/api/user"
 	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/settings"
+	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/term"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/retry"
 )
 
 func RegisterPasswordResetCommand() {
@@ -23,64 +41,856 @@ func RegisterPasswordResetCommand() {
 
 const (
 	length     = 20
-	cost       = 10
 	characters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_"
+	// symbolCharacters is used for generated passwords of a user-selected length so that they satisfy complexity policies.
+	symbolCharacters  = characters + "!@#$%^&*()+=[]{}<>?,.:;~"
+	minPasswordLength = 12
+
+	outputJSON  = "json"
+	outputTable = "table"
+
+	// loginPath is the path of the UI login page, relative to the server URL.
+	loginPath = "/dashboard/auth/login"
 )
 
+// resetPasswordResult is the outcome of a reset, as printed by reset-password.
+type resetPasswordResult struct {
+	adminName          string
+	Username           string `json:"username"`
+	Password           string `json:"password,omitempty"`
+	ServerURL          string `json:"serverURL"`
+	LoginURL           string `json:"loginURL,omitempty"`
+	MustChangePassword bool   `json:"mustChangePassword"`
+}
+
+type resetPasswordOptions struct {
+	PasswordStdin  bool
+	Username       string
+	DryRun         bool
+	BcryptCost     int
+	Output         string
+	Wait           bool
+	WaitTimeout    time.Duration
+	ServerURL      string
+	KubeConfig     string
+	Context        string
+	PasswordLength int
+	// MustChangePassword and NoMustChangePassword override whether the admin must change the new password on next login.
+	MustChangePassword   bool
+	NoMustChangePassword bool
+	Retries              int
+	RetryInterval        time.Duration
+	List                 bool
+	Timeout              time.Duration
+	AdminLabel           string
+	Quiet                bool
+	Interactive          bool
+	PrintKubeConfigUsed  bool
+	ShowServerURL        bool
+	Rotate               bool
+	Show                 bool
+	WriteCredentials     string
+	Force                bool
+}
+
+type serverURLOutput struct {
+	ServerURL string `json:"serverURL"`
+	LoginURL  string `json:"loginURL,omitempty"`
+}
+
+type adminStateOutput struct {
+	Username           string `json:"username"`
+	MustChangePassword bool   `json:"mustChangePassword"`
+	ServerURL          string `json:"serverURL"`
+	LoginURL           string `json:"loginURL,omitempty"`
+}
+
+type adminOutput struct {
+	Name               string `json:"name"`
+	Username           string `json:"username"`
+	DisplayName        string `json:"displayName"`
+	MustChangePassword bool   `json:"mustChangePassword"`
+}
+
 func resetPassword() {
 	app := cli.NewApp()
 	app.Description = "Reset the password for the default admin user"
 
+	opts := resetPasswordOptions{}
+	app.Flags = []cli.Flag{
+		cli.BoolFlag{
+			Name:        "password-stdin",
+			Usage:       "Read the new password from stdin instead of generating one",
+			Destination: &opts.PasswordStdin,
+		},
+		cli.StringFlag{
+			Name:        "username",
+			Usage:       "Username of the labeled admin to reset when more than one exists",
+			Destination: &opts.Username,
+		},
+		cli.BoolFlag{
+			Name:        "dry-run",
+			Usage:       "Show which admin user would be reset without changing anything",
+			Destination: &opts.DryRun,
+		},
+		cli.IntFlag{
+			Name:        "bcrypt-cost",
+			Usage:       "Bcrypt cost used to hash the new password",
+			Value:       bcrypt.DefaultCost,
+			Destination: &opts.BcryptCost,
+		},
+		cli.StringFlag{
+			Name:        "output",
+			Usage:       "Output format (json, table)",
+			Destination: &opts.Output,
+		},
+		cli.BoolFlag{
+			Name:        "wait",
+			Usage:       "Wait for the users resource and the default admin user to exist",
+			Destination: &opts.Wait,
+		},
+		cli.DurationFlag{
+			Name:        "wait-timeout",
+			Usage:       "Maximum time to wait when --wait is set",
+			Value:       5 * time.Minute,
+			Destination: &opts.WaitTimeout,
+		},
+		cli.StringFlag{
+			Name:        "server-url",
+			Usage:       "External https URL of the server, stored in the server-url setting if it is unset",
+			Destination: &opts.ServerURL,
+		},
+		cli.StringFlag{
+			Name:        "kubeconfig",
+			Usage:       "Kube config for accessing k8s cluster, defaults to the paths in $KUBECONFIG or $HOME/.kube/config",
+			Destination: &opts.KubeConfig,
+		},
+		cli.StringFlag{
+			Name:        "context",
+			Usage:       "Kubeconfig context to use instead of the current context",
+			Destination: &opts.Context,
+		},
+		cli.IntFlag{
+			Name:        "password-length",
+			Usage:       "Length of a generated password, which then also includes symbols",
+			Destination: &opts.PasswordLength,
+		},
+		cli.BoolFlag{
+			Name:        "must-change-password",
+			Usage:       "Require the admin to change the new password on next login",
+			Destination: &opts.MustChangePassword,
+		},
+		cli.BoolFlag{
+			Name:        "no-must-change-password",
+			Usage:       "Do not require the admin to change the new password on next login (default)",
+			Destination: &opts.NoMustChangePassword,
+		},
+		cli.IntFlag{
+			Name:        "retries",
+			Usage:       "Number of attempts for API calls that fail with a conflict or the server being unavailable",
+			Value:       5,
+			Destination: &opts.Retries,
+		},
+		cli.DurationFlag{
+			Name:        "retry-interval",
+			Usage:       "Initial interval between attempts, doubled after each failure",
+			Value:       time.Second,
+			Destination: &opts.RetryInterval,
+		},
+		cli.BoolFlag{
+			Name:        "list",
+			Usage:       "List the users labeled as the default admin without changing anything",
+			Destination: &opts.List,
+		},
+		cli.DurationFlag{
+			Name:        "timeout",
+			Usage:       "Maximum time for the whole command, including waiting and retries",
+			Value:       5 * time.Minute,
+			Destination: &opts.Timeout,
+		},
+		adminLabelFlag(&opts.AdminLabel),
+		cli.BoolFlag{
+			Name:        "quiet",
+			Usage:       "Only print the new credentials, or the structured output, and errors",
+			Destination: &opts.Quiet,
+		},
+		cli.BoolFlag{
+			Name:        "interactive",
+			Usage:       "Prompt twice for the new password on the terminal without echoing it",
+			Destination: &opts.Interactive,
+		},
+		cli.BoolFlag{
+			Name:        "print-kubeconfig-used",
+			Usage:       "Log the kubeconfig path and context used to connect",
+			Destination: &opts.PrintKubeConfigUsed,
+		},
+		cli.BoolFlag{
+			Name:        "show-server-url",
+			Usage:       "Print the server URL the reset would report, without changing anything",
+			Destination: &opts.ShowServerURL,
+		},
+		cli.BoolFlag{
+			Name:        "rotate",
+			Usage:       "Set a new random temporary password that the admin must change on next login",
+			Destination: &opts.Rotate,
+		},
+		cli.BoolFlag{
+			Name:        "show",
+			Usage:       "Print the username, mustChangePassword and server URL of the default admin without changing anything",
+			Destination: &opts.Show,
+		},
+		cli.StringFlag{
+			Name:        "write-credentials",
+			Usage:       "Write the new credentials as JSON to the given file, readable only by its owner, instead of printing the password",
+			Destination: &opts.WriteCredentials,
+		},
+		cli.BoolFlag{
+			Name:        "force",
+			Usage:       "Overwrite the file given by --write-credentials if it exists",
+			Destination: &opts.Force,
+		},
+	}
+
 	app.Action = func(c *cli.Context) error {
-		kubeConfigPath := os.ExpandEnv("$HOME/.kube/config")
-		if _, err := os.Stat(kubeConfigPath); err != nil {
-			kubeConfigPath = ""
+		if err := opts.validate(); err != nil {
+			return err
+		}
+		if opts.WriteCredentials != "" {
+			// Fail before the reset, so that the new password is not lost.
+			if err := checkCredentialsPath(opts.WriteCredentials, opts.Force); err != nil {
+				return err
+			}
+		}
+		out, err := resetAdminPassword(opts)
+		if err != nil || out == nil {
+			return err
+		}
+		if opts.WriteCredentials != "" {
+			if err := writeCredentials(opts.WriteCredentials, opts.Force, *out); err != nil {
+				// The password was already reset, so print it rather than losing it.
+				_ = printResult(os.Stdout, os.Stderr, opts.Output, opts.Quiet, *out)
+				return err
+			}
+			if !opts.Quiet {
+				fmt.Fprintf(os.Stderr, "Credentials written to %v\n", opts.WriteCredentials)
+			}
+			out.Password = ""
 		}
+		return printResult(os.Stdout, os.Stderr, opts.Output, opts.Quiet, *out)
+	}
+
+	err := app.Run(os.Args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
 
-		conf, err := clientcmd.BuildConfigFromFlags("", kubeConfigPath)
+func (o resetPasswordOptions) validate() error {
+	if o.BcryptCost < bcrypt.MinCost || o.BcryptCost > bcrypt.MaxCost {
+		return errors.Errorf("--bcrypt-cost must be between %v and %v", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	if o.Output != "" && o.Output != outputJSON && o.Output != outputTable {
+		return errors.Errorf("unsupported --output %v, must be %v or %v", o.Output, outputJSON, outputTable)
+	}
+	if o.MustChangePassword && o.NoMustChangePassword {
+		return errors.New("--must-change-password and --no-must-change-password are mutually exclusive")
+	}
+	if o.List && o.PasswordStdin {
+		return errors.New("--list and --password-stdin are mutually exclusive")
+	}
+	if o.Interactive && (o.PasswordStdin || o.List) {
+		return errors.New("--interactive can't be combined with --password-stdin or --list")
+	}
+	if o.ShowServerURL && (o.PasswordStdin || o.Interactive || o.List) {
+		return errors.New("--show-server-url can't be combined with --password-stdin, --interactive or --list")
+	}
+	if o.Rotate && (o.PasswordStdin || o.Interactive || o.List || o.ShowServerURL || o.NoMustChangePassword) {
+		return errors.New("--rotate can't be combined with --password-stdin, --interactive, --list, --show-server-url or --no-must-change-password")
+	}
+	if o.Show && (o.PasswordStdin || o.Interactive || o.List || o.ShowServerURL || o.Rotate || o.DryRun) {
+		return errors.New("--show can't be combined with --password-stdin, --interactive, --list, --show-server-url, --rotate or --dry-run")
+	}
+	if o.WriteCredentials != "" && (o.List || o.Show || o.ShowServerURL || o.DryRun) {
+		return errors.New("--write-credentials can't be combined with --list, --show, --show-server-url or --dry-run")
+	}
+	if o.Force && o.WriteCredentials == "" {
+		return errors.New("--force requires --write-credentials")
+	}
+	if o.Timeout <= 0 {
+		return errors.New("--timeout must be positive")
+	}
+	if o.Retries < 1 {
+		return errors.New("--retries must be at least 1")
+	}
+	if o.PasswordLength != 0 && o.PasswordLength < minPasswordLength {
+		return errors.Errorf("--password-length must be at least %v", minPasswordLength)
+	}
+	if err := settings.ServerURL.Validate(o.ServerURL); err != nil {
+		return errors.Errorf("invalid --server-url: %v", err)
+	}
+	if _, err := parseAdminLabel(o.AdminLabel); err != nil {
+		return err
+	}
+	return nil
+}
+
+// resetAdminPassword resets the password of the default admin and returns the new credentials.
+// The result is nil when nothing was reset, for --list, --show and --dry-run, which print their own output.
+func resetAdminPassword(opts resetPasswordOptions) (*resetPasswordResult, error) {
+	if opts.Quiet {
+		level := logrus.GetLevel()
+		logrus.SetLevel(logrus.WarnLevel)
+		defer logrus.SetLevel(level)
+	}
+
+	var pass []byte
+	if opts.PasswordStdin {
+		var err error
+		pass, err = readPassword(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.Interactive {
+		fd := int(os.Stdin.Fd())
+		if !term.IsTerminal(fd) {
+			return nil, errors.New("--interactive requires stdin to be a terminal")
+		}
+		var err error
+		pass, err = promptPassword(os.Stderr, func() ([]byte, error) {
+			return term.ReadPassword(fd)
+		})
 		if err != nil {
-			return fmt.Errorf("Couldn't get kubeconfig. %v", err)
+			return nil, err
 		}
+	}
+
+	loadingRules, kubeConfigPath, err := resolveKubeConfig(opts.KubeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if kubeConfigPath == "" && opts.Context != "" {
+		return nil, errors.Errorf("--context %v requires a kubeconfig", opts.Context)
+	}
+
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		loadingRules,
+		&clientcmd.ConfigOverrides{CurrentContext: opts.Context},
+	)
+	if opts.PrintKubeConfigUsed {
+		logKubeConfigUsed(kubeConfigPath, opts.Context, clientConfig)
+	}
+	conf, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't get kubeconfig. %v", err)
+	}
+
+	// The norman clients don't take a context, so the deadline bounds each request through the rest config
+	// and the waits and retries through ctx.
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+	conf.Timeout = opts.Timeout
+	retriable := func(err error) bool {
+		return ctx.Err() == nil && isRetriable(err)
+	}
+
+	client, err := v3.NewForConfig(*conf)
+	if err != nil {
+		return nil, errors.Errorf("Couldn't get kubernetes client. %v", err)
+	}
 
-		client, err := v3.NewForConfig(*conf)
+	if opts.ShowServerURL {
+		var serverURL string
+		err = retry.OnError(opts.backoff(), retriable, func() error {
+			var err error
+			serverURL, err = getServerURL(client)
+			return err
+		})
 		if err != nil {
-			return errors.Errorf("Couldn't get kubernetes client. %v", err)
+			return nil, errors.Errorf("Couldn't get %v setting. %v", settings.ServerURL.Name, timeoutError(ctx, opts.Timeout, err))
+		}
+		if serverURL == "" {
+			// The reset would store --server-url, since the setting is unset.
+			serverURL = opts.ServerURL
+		}
+		return nil, printServerURL(os.Stdout, opts.Output, serverURL)
+	}
+
+	set, err := parseAdminLabel(opts.AdminLabel)
+	if err != nil {
+		return nil, err
+	}
+	var admins []v3.User
+	if opts.Wait {
+		admins, err = waitForAdmins(ctx, client, set, opts.WaitTimeout)
+	} else {
+		err = retry.OnError(opts.backoff(), retriable, func() error {
+			var err error
+			admins, err = listAdmins(client, set)
+			return err
+		})
+	}
+	if err != nil {
+		return nil, errors.Errorf("Couldn't get default admin user. %v", timeoutError(ctx, opts.Timeout, err))
+	}
+
+	if opts.List {
+		return nil, printAdmins(os.Stdout, opts.Output, admins)
+	}
+
+	admin, err := selectAdmin(admins, set, opts.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Show {
+		var serverURL string
+		_ = retry.OnError(opts.backoff(), retriable, func() error {
+			var err error
+			serverURL, err = getServerURL(client)
+			return err
+		})
+		return nil, printAdminState(os.Stdout, os.Stderr, opts.Output, adminStateOutput{
+			Username:           admin.Username,
+			MustChangePassword: admin.MustChangePassword,
+			ServerURL:          serverURL,
+			LoginURL:           loginURL(serverURL),
+		})
+	}
+
+	if opts.DryRun {
+		fmt.Fprintf(os.Stderr, "Dry run: would reset the password for default admin user (%v) and set mustChangePassword to %v\n", admin.Name, opts.mustChangePassword())
+		if opts.ServerURL != "" {
+			fmt.Fprintf(os.Stderr, "Dry run: would set the %v setting to %v if it is unset\n", settings.ServerURL.Name, opts.ServerURL)
 		}
+		return nil, nil
+	}
 
-		set := labels.Set(map[string]string{"authz.management.cattle.io/bootstrapping": "admin-user"})
-		admins, err := client.Users("").List(v1.ListOptions{LabelSelector: set.String()})
+	generated := pass == nil
+	if generated {
+		if opts.PasswordLength != 0 {
+			pass, err = generateSymbolPassword(opts.PasswordLength)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			pass = generatePassword(length)
+		}
+	}
+	hashedPass, err := bcrypt.GenerateFromPassword(pass, opts.BcryptCost)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem encrypting password")
+	}
+	updated, err := updateAdminPassword(client, opts.backoff(), retriable, admin.Name, hashedPass, opts.mustChangePassword())
+	if err != nil {
+		return nil, errors.Errorf("Couldn't update default admin user. %v", timeoutError(ctx, opts.Timeout, err))
+	}
+	admin = *updated
+
+	serverURL := opts.ServerURL
+	if serverURL != "" {
+		err = retry.OnError(opts.backoff(), retriable, func() error {
+			return setServerURLIfUnset(client, serverURL)
+		})
 		if err != nil {
-			return errors.Errorf("Couldn't get default admin user. %v", err)
+			return nil, errors.Errorf("Couldn't update %v setting. %v", settings.ServerURL.Name, timeoutError(ctx, opts.Timeout, err))
+		}
+	} else {
+		_ = retry.OnError(opts.backoff(), retriable, func() error {
+			var err error
+			serverURL, err = getServerURL(client)
+			return err
+		})
+	}
+
+	out := &resetPasswordResult{
+		adminName:          admin.Name,
+		Username:           admin.Username,
+		ServerURL:          serverURL,
+		LoginURL:           loginURL(serverURL),
+		MustChangePassword: admin.MustChangePassword,
+	}
+	if generated {
+		out.Password = string(pass)
+	}
+	return out, nil
+}
+
+// printResult writes the outcome of a reset. Structured output is written to stdout, while the human-readable
+// messages, including a generated password, are written to stderr so that stdout can be captured by scripts.
+// When quiet is set, only a generated password is written to stderr.
+func printResult(stdout, stderr io.Writer, format string, quiet bool, out resetPasswordResult) error {
+	switch format {
+	case outputJSON:
+		return json.NewEncoder(stdout).Encode(out)
+	case outputTable:
+		w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "USERNAME\t%v\n", out.Username)
+		if out.Password != "" {
+			fmt.Fprintf(w, "PASSWORD\t%v\n", out.Password)
+		}
+		fmt.Fprintf(w, "MUST CHANGE PASSWORD\t%v\n", out.MustChangePassword)
+		fmt.Fprintf(w, "SERVER URL\t%v\n", out.ServerURL)
+		if out.LoginURL != "" {
+			fmt.Fprintf(w, "LOGIN URL\t%v\n", out.LoginURL)
 		}
+		return w.Flush()
+	}
+	if out.Password != "" {
+		fmt.Fprintf(stderr, "New password for default admin user (%v):\n%s\n", out.adminName, out.Password)
+	} else if !quiet {
+		fmt.Fprintf(stderr, "Password for default admin user (%v) has been reset\n", out.adminName)
+	}
+	if out.LoginURL != "" && !quiet {
+		fmt.Fprintf(stderr, "Log in at %v\n", out.LoginURL)
+	}
+	return nil
+}
 
-		count := len(admins.Items)
+// printAdminState writes the current state of the default admin to stdout. The password is stored hashed and can't
+// be recovered, which is pointed out on stderr so that stdout can still be captured by scripts.
+func printAdminState(stdout, stderr io.Writer, format string, state adminStateOutput) error {
+	if format == outputJSON {
+		if err := json.NewEncoder(stdout).Encode(state); err != nil {
+			return err
+		}
+	} else {
+		w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "USERNAME\t%v\n", state.Username)
+		fmt.Fprintf(w, "MUST CHANGE PASSWORD\t%v\n", state.MustChangePassword)
+		fmt.Fprintf(w, "SERVER URL\t%v\n", state.ServerURL)
+		if state.LoginURL != "" {
+			fmt.Fprintf(w, "LOGIN URL\t%v\n", state.LoginURL)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintln(stderr, "The password is stored hashed and can't be displayed; run reset-password without --show to set a new one")
+	return nil
+}
+
+// checkCredentialsPath returns an error if the credentials file exists and force is not set.
+func checkCredentialsPath(path string, force bool) error {
+	if force {
+		return nil
+	}
+	if _, err := os.Lstat(path); err == nil {
+		return errors.Errorf("%v already exists, use --force to overwrite it", path)
+	} else if !os.IsNotExist(err) {
+		return errors.Errorf("Couldn't check %v. %v", path, err)
+	}
+	return nil
+}
+
+// writeCredentials writes the result as JSON to path with 0600 permissions. The file is written to a temporary
+// file in the same directory first and then moved into place, so that it is never left partially written.
+// An existing file is only replaced if force is set.
+func writeCredentials(path string, force bool, out resetPasswordResult) error {
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-")
+	if err != nil {
+		return errors.Errorf("Couldn't write credentials. %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return errors.Errorf("Couldn't write credentials. %v", err)
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return errors.Errorf("Couldn't write credentials. %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Errorf("Couldn't write credentials. %v", err)
+	}
+	if force {
+		err = os.Rename(tmp.Name(), path)
+	} else {
+		// Unlike a rename, a link never replaces a file created since checkCredentialsPath.
+		err = os.Link(tmp.Name(), path)
+	}
+	if os.IsExist(err) {
+		return errors.Errorf("%v already exists, use --force to overwrite it", path)
+	} else if err != nil {
+		return errors.Errorf("Couldn't write credentials. %v", err)
+	}
+	return nil
+}
+
+// printServerURL writes the given server URL to stdout, or a JSON object with it and the login URL.
+func printServerURL(stdout io.Writer, format, serverURL string) error {
+	if format == outputJSON {
+		return json.NewEncoder(stdout).Encode(serverURLOutput{
+			ServerURL: serverURL,
+			LoginURL:  loginURL(serverURL),
+		})
+	}
+	fmt.Fprintln(stdout, serverURL)
+	return nil
+}
+
+// loginURL returns the URL of the UI login page of the given server, or an empty string if there is no server URL.
+func loginURL(serverURL string) string {
+	if serverURL == "" {
+		return ""
+	}
+	return strings.TrimSuffix(serverURL, "/") + loginPath
+}
+
+// printAdmins writes the given admins to stdout, one per line or as a JSON list.
+func printAdmins(stdout io.Writer, format string, admins []v3.User) error {
+	out := make([]adminOutput, 0, len(admins))
+	for _, admin := range admins {
+		out = append(out, adminOutput{
+			Name:               admin.Name,
+			Username:           admin.Username,
+			DisplayName:        admin.DisplayName,
+			MustChangePassword: admin.MustChangePassword,
+		})
+	}
+	if format == outputJSON {
+		return json.NewEncoder(stdout).Encode(out)
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tUSERNAME\tDISPLAY NAME\tMUST CHANGE PASSWORD")
+	for _, admin := range out {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", admin.Name, admin.Username, admin.DisplayName, admin.MustChangePassword)
+	}
+	return w.Flush()
+}
+
+// mustChangePassword returns whether the admin must change the new password on next login,
+// which is always the case for a rotated password.
+func (o resetPasswordOptions) mustChangePassword() bool {
+	return o.MustChangePassword || o.Rotate
+}
+
+// updateAdminPassword stores the hashed password on the admin with the given name, retrying with the given
+// backoff while the errors are retriable. The admin is read again on each attempt, so that conflicts resolve.
+func updateAdminPassword(client v3.UsersGetter, backoff wait.Backoff, retriable func(error) bool, name string, hashedPass []byte, mustChangePassword bool) (*v3.User, error) {
+	var updated *v3.User
+	err := retry.OnError(backoff, retriable, func() error {
+		current, err := client.Users("").Get(name, v1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		current.Password = string(hashedPass)
+		current.MustChangePassword = mustChangePassword
+		updated, err = client.Users("").Update(current)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// backoff returns the backoff used to retry API calls that failed with a transient error.
+func (o resetPasswordOptions) backoff() wait.Backoff {
+	return wait.Backoff{
+		Steps:    o.Retries,
+		Duration: o.RetryInterval,
+		Factor:   2,
+		Jitter:   0.1,
+	}
+}
+
+// isRetriable returns true for API errors that are expected to go away on their own, such as
+// conflicting updates or the API server being briefly unavailable after a restart.
+func isRetriable(err error) bool {
+	return apierrors.IsConflict(err) ||
+		apierrors.IsServiceUnavailable(err) ||
+		apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsTooManyRequests(err)
+}
+
+// isUsersResourceMissing reports whether err means the users CRD is not registered with the API server.
+func isUsersResourceMissing(err error) bool {
+	return apimeta.IsNoMatchError(err) || apierrors.IsNotFound(err)
+}
+
+// resolveKubeConfig returns the loading rules for the kubeconfig to connect with and the path it is loaded from.
+// A path given with --kubeconfig must exist. Otherwise the paths in $KUBECONFIG are loaded by clientcmd, or else
+// $HOME/.kube/config if present, and an empty path selects the in-cluster config.
+func resolveKubeConfig(path string) (*clientcmd.ClientConfigLoadingRules, string, error) {
+	if path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return nil, "", errors.Errorf("Couldn't read kubeconfig %v. %v", path, err)
+		}
+		return &clientcmd.ClientConfigLoadingRules{ExplicitPath: path}, path, nil
+	}
+
+	// $KUBECONFIG may hold a list of paths, which clientcmd merges.
+	if env := os.Getenv(clientcmd.RecommendedConfigPathEnvVar); env != "" {
+		return clientcmd.NewDefaultClientConfigLoadingRules(), env, nil
+	}
+
+	path = os.ExpandEnv("$HOME/.kube/config")
+	if _, err := os.Stat(path); err != nil {
+		return &clientcmd.ClientConfigLoadingRules{}, "", nil
+	}
+	return &clientcmd.ClientConfigLoadingRules{ExplicitPath: path}, path, nil
+}
+
+// logKubeConfigUsed logs the kubeconfig path and context that the command connects with.
+// The context is the one given with --context, or else the current context of the kubeconfig.
+func logKubeConfigUsed(path, contextName string, clientConfig clientcmd.ClientConfig) {
+	if path == "" {
+		logrus.Infof("Using in-cluster config")
+		return
+	}
+	if contextName == "" {
+		if raw, err := clientConfig.RawConfig(); err == nil {
+			contextName = raw.CurrentContext
+		}
+	}
+	logrus.Infof("Using kubeconfig %v with context %v", path, contextName)
+}
+
+// errRancherNotInstalled is returned when the target cluster does not serve the users resource.
+var errRancherNotInstalled = errors.New("the users resource was not found: Rancher is not installed or not ready on the target cluster")
+
+// listAdmins returns the users that carry the given label.
+func listAdmins(client v3.UsersGetter, set labels.Set) ([]v3.User, error) {
+	admins, err := client.Users("").List(v1.ListOptions{LabelSelector: set.String()})
+	if err != nil {
+		if isUsersResourceMissing(err) {
+			return nil, errRancherNotInstalled
+		}
+		return nil, err
+	}
+	return admins.Items, nil
+}
+
+// waitForAdmins polls with exponential backoff until at least one user carries the given label or the timeout expires.
+func waitForAdmins(ctx context.Context, client v3.Interface, set labels.Set, timeout time.Duration) ([]v3.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	backoff := wait.Backoff{
+		Duration: time.Second,
+		Factor:   2,
+		Cap:      30 * time.Second,
+		Steps:    math.MaxInt32,
+	}
+
+	var (
+		admins  []v3.User
+		lastErr error
+	)
+	err := wait.ExponentialBackoffWithContext(ctx, backoff, func() (bool, error) {
+		admins, lastErr = listAdmins(client, set)
+		if lastErr != nil {
+			logrus.Debugf("Waiting for default admin user: %v", lastErr)
+			return false, nil
+		}
+		return len(admins) > 0, nil
+	})
+	if err != nil {
+		if lastErr != nil {
+			return nil, errors.Errorf("timed out waiting for default admin user. %v", lastErr)
+		}
+		return nil, errors.Errorf("timed out waiting for a user with %v label", set)
+	}
+	return admins, nil
+}
+
+// adminLabelFlag returns the flag that overrides the label used to find the default admin.
+func adminLabelFlag(destination *string) cli.StringFlag {
+	return cli.StringFlag{
+		Name:        "admin-label",
+		Usage:       "Label in key=value form that identifies the default admin user",
+		Value:       defaultAdminLabelKey + "=" + defaultAdminLabelValue,
+		Destination: destination,
+	}
+}
+
+// parseAdminLabel parses a key=value admin label, as given to --admin-label, into a label set.
+// An empty label selects the default admin label.
+func parseAdminLabel(label string) (labels.Set, error) {
+	if label == "" {
+		return labels.Set(defaultAdminLabel), nil
+	}
+	key, value, ok := strings.Cut(label, "=")
+	if !ok {
+		return nil, errors.Errorf("--admin-label %v must be in key=value form", label)
+	}
+	if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+		return nil, errors.Errorf("invalid --admin-label key %v: %v", key, strings.Join(errs, "; "))
+	}
+	if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+		return nil, errors.Errorf("invalid --admin-label value %v: %v", value, strings.Join(errs, "; "))
+	}
+	return labels.Set{key: value}, nil
+}
+
+// timeoutError replaces err with a clear message when it was caused by the --timeout deadline.
+func timeoutError(ctx context.Context, timeout time.Duration, err error) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return errors.Errorf("timed out after %v: %v", timeout, err)
+	}
+	return err
+}
+
+// selectAdmin returns the admin to reset. Without a username there must be exactly one labeled admin,
+// otherwise the admin whose username matches is returned.
+func selectAdmin(admins []v3.User, set labels.Set, username string) (v3.User, error) {
+	if username == "" {
+		count := len(admins)
 		if count != 1 {
 			var users []string
-			for _, u := range admins.Items {
+			for _, u := range admins {
 				users = append(users, u.Name)
 			}
-			return errors.Errorf("%v users were found with %v label. They are %v. Can only reset the default admin password when there is exactly one user with this label",
+			return v3.User{}, errors.Errorf("%v users were found with %v label. They are %v. Can only reset the default admin password when there is exactly one user with this label",
 				count, set, users)
 		}
+		return admins[0], nil
+	}
 
-		admin := admins.Items[0]
-		pass := generatePassword(length)
-		hashedPass, err := user.HashPasswordString(string(pass))
-		if err != nil {
-			return err
+	var usernames []string
+	for _, u := range admins {
+		if u.Username == username {
+			return u, nil
 		}
-		admin.Password = hashedPass
-		admin.MustChangePassword = false
-		_, err = client.Users("").Update(&admin)
-		fmt.Fprintf(os.Stdout, "New password for default admin user (%v):\n%s\n", admin.Name, pass)
-		return err
+		usernames = append(usernames, u.Username)
 	}
+	return v3.User{}, errors.Errorf("No user with username %v was found with %v label. Available usernames are %v", username, set, usernames)
+}
 
-	err := app.Run(os.Args)
+// getServerURL returns the value of the server-url setting stored in the cluster.
+func getServerURL(client v3.Interface) (string, error) {
+	setting, err := client.Settings("").Get(settings.ServerURL.Name, v1.GetOptions{})
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		return "", err
+	}
+	if setting.Value != "" {
+		return setting.Value, nil
+	}
+	return setting.Default, nil
+}
+
+// setServerURLIfUnset stores the given URL in the server-url setting if the setting has no value yet.
+func setServerURLIfUnset(client v3.Interface, serverURL string) error {
+	setting, err := client.Settings("").Get(settings.ServerURL.Name, v1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if setting.Value != "" {
+		return nil
 	}
+	setting.Value = serverURL
+	_, err = client.Settings("").Update(setting)
+	return err
 }
 
 func generatePassword(length int) []byte {
@@ -98,3 +908,87 @@ func generatePassword(length int) []byte {
 
 	return out
 }
+
+// generateSymbolPassword returns a password of the given length drawn uniformly from symbolCharacters.
+func generateSymbolPassword(length int) ([]byte, error) {
+	count := big.NewInt(int64(len(symbolCharacters)))
+	out := make([]byte, length)
+	for i := range out {
+		index, err := rand.Int(rand.Reader, count)
+		if err != nil {
+			return nil, errors.Wrap(err, "problem generating password")
+		}
+		out[i] = symbolCharacters[index.Int64()]
+	}
+	return out, nil
+}
+
+// promptPassword asks for the new password twice, reading each entry with read, and returns it if both entries match.
+func promptPassword(prompt io.Writer, read func() ([]byte, error)) ([]byte, error) {
+	fmt.Fprint(prompt, "New password: ")
+	pass, err := read()
+	fmt.Fprintln(prompt)
+	if err != nil {
+		return nil, errors.Errorf("Couldn't read password. %v", err)
+	}
+	if len(pass) == 0 {
+		return nil, errors.New("password is empty")
+	}
+
+	fmt.Fprint(prompt, "Confirm password: ")
+	confirmation, err := read()
+	fmt.Fprintln(prompt)
+	if err != nil {
+		return nil, errors.Errorf("Couldn't read password. %v", err)
+	}
+	if !bytes.Equal(pass, confirmation) {
+		return nil, errors.New("passwords do not match")
+	}
+	return pass, nil
+}
+
+// readPassword reads a password piped through the given file. Only the first non-empty line is used, so that
+// newlines never become part of the password, and a warning is logged if the file has further non-empty lines.
+func readPassword(f *os.File) ([]byte, error) {
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, errors.Errorf("Couldn't read password from stdin. %v", err)
+	}
+	if stat.Mode()&os.ModeCharDevice != 0 {
+		return nil, errors.New("--password-stdin requires the password to be piped through stdin")
+	}
+
+	pass, err := io.ReadAll(f)
+	if err != nil {
+		return nil, errors.Errorf("Couldn't read password from stdin. %v", err)
+	}
+	pass, extra := firstLine(pass)
+	if len(pass) == 0 {
+		return nil, errors.New("password read from stdin is empty")
+	}
+	if extra > 0 {
+		logrus.Warnf("Ignoring %v more non-empty lines after the password read from stdin", extra)
+	}
+	return pass, nil
+}
+
+// firstLine returns the first non-empty line of data without its line ending, and the number of
+// non-empty lines that follow it.
+func firstLine(data []byte) ([]byte, int) {
+	var (
+		line  []byte
+		extra int
+	)
+	for _, l := range bytes.Split(data, []byte("\n")) {
+		l = bytes.TrimSuffix(l, []byte("\r"))
+		if len(l) == 0 {
+			continue
+		}
+		if line == nil {
+			line = l
+		} else {
+			extra++
+		}
+	}
+	return line, extra
+}
@@ -0,0 +1,537 @@
+package management
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	normanv3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3/fakes"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func TestSelectAdmin(t *testing.T) {
+	set := labels.Set(defaultAdminLabel)
+	admin := v3.User{ObjectMeta: v1.ObjectMeta{Name: "user-abc"}, Username: "admin"}
+	other := v3.User{ObjectMeta: v1.ObjectMeta{Name: "user-def"}, Username: "other"}
+
+	tests := []struct {
+		name     string
+		admins   []v3.User
+		username string
+		want     string
+		wantErr  bool
+	}{
+		{name: "single admin", admins: []v3.User{admin}, want: "user-abc"},
+		{name: "no admins", admins: nil, wantErr: true},
+		{name: "multiple admins without username", admins: []v3.User{admin, other}, wantErr: true},
+		{name: "multiple admins with username", admins: []v3.User{admin, other}, username: "other", want: "user-def"},
+		{name: "unknown username", admins: []v3.User{admin, other}, username: "missing", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectAdmin(tt.admins, set, tt.username)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got.Name)
+		})
+	}
+}
+
+func TestResetPasswordOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    resetPasswordOptions
+		wantErr bool
+	}{
+		{name: "defaults", opts: resetPasswordOptions{}},
+		{name: "bcrypt cost too low", opts: resetPasswordOptions{BcryptCost: bcrypt.MinCost - 1}, wantErr: true},
+		{name: "bcrypt cost too high", opts: resetPasswordOptions{BcryptCost: bcrypt.MaxCost + 1}, wantErr: true},
+		{name: "json output", opts: resetPasswordOptions{Output: outputJSON}},
+		{name: "unknown output", opts: resetPasswordOptions{Output: "xml"}, wantErr: true},
+		{name: "https server url", opts: resetPasswordOptions{ServerURL: "https://rancher.example.com"}},
+		{name: "http server url", opts: resetPasswordOptions{ServerURL: "http://rancher.example.com"}, wantErr: true},
+		{name: "password length", opts: resetPasswordOptions{PasswordLength: 32}},
+		{name: "password length too short", opts: resetPasswordOptions{PasswordLength: 8}, wantErr: true},
+		{name: "must change password", opts: resetPasswordOptions{MustChangePassword: true}},
+		{name: "conflicting must change password", opts: resetPasswordOptions{MustChangePassword: true, NoMustChangePassword: true}, wantErr: true},
+		{name: "negative timeout", opts: resetPasswordOptions{Timeout: -time.Second}, wantErr: true},
+		{name: "admin label", opts: resetPasswordOptions{AdminLabel: "example.com/admin=true"}},
+		{name: "admin label without value", opts: resetPasswordOptions{AdminLabel: "example.com/admin"}, wantErr: true},
+		{name: "interactive", opts: resetPasswordOptions{Interactive: true}},
+		{name: "interactive with password", opts: resetPasswordOptions{Interactive: true, PasswordStdin: true}, wantErr: true},
+		{name: "show server url", opts: resetPasswordOptions{ShowServerURL: true}},
+		{name: "show server url with list", opts: resetPasswordOptions{ShowServerURL: true, List: true}, wantErr: true},
+		{name: "table output", opts: resetPasswordOptions{Output: outputTable}},
+		{name: "list", opts: resetPasswordOptions{List: true}},
+		{name: "list with password", opts: resetPasswordOptions{List: true, PasswordStdin: true}, wantErr: true},
+		{name: "no retries", opts: resetPasswordOptions{Retries: -1}, wantErr: true},
+		{name: "relative server url", opts: resetPasswordOptions{ServerURL: "rancher.example.com"}, wantErr: true},
+		{name: "rotate", opts: resetPasswordOptions{Rotate: true}},
+		{name: "rotate with must change password", opts: resetPasswordOptions{Rotate: true, MustChangePassword: true}},
+		{name: "rotate with password", opts: resetPasswordOptions{Rotate: true, PasswordStdin: true}, wantErr: true},
+		{name: "rotate without must change password", opts: resetPasswordOptions{Rotate: true, NoMustChangePassword: true}, wantErr: true},
+		{name: "show", opts: resetPasswordOptions{Show: true}},
+		{name: "show with dry run", opts: resetPasswordOptions{Show: true, DryRun: true}, wantErr: true},
+		{name: "show with rotate", opts: resetPasswordOptions{Show: true, Rotate: true}, wantErr: true},
+		{name: "write credentials", opts: resetPasswordOptions{WriteCredentials: "credentials.json", Force: true}},
+		{name: "write credentials with list", opts: resetPasswordOptions{WriteCredentials: "credentials.json", List: true}, wantErr: true},
+		{name: "force without write credentials", opts: resetPasswordOptions{Force: true}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.opts.BcryptCost == 0 {
+				tt.opts.BcryptCost = bcrypt.DefaultCost
+			}
+			if tt.opts.Retries == 0 {
+				tt.opts.Retries = 5
+			}
+			if tt.opts.Timeout == 0 {
+				tt.opts.Timeout = 5 * time.Minute
+			}
+			err := tt.opts.validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestMustChangePassword(t *testing.T) {
+	assert.False(t, resetPasswordOptions{}.mustChangePassword())
+	assert.True(t, resetPasswordOptions{MustChangePassword: true}.mustChangePassword())
+	assert.True(t, resetPasswordOptions{Rotate: true}.mustChangePassword())
+}
+
+func TestGenerateSymbolPassword(t *testing.T) {
+	pass, err := generateSymbolPassword(64)
+	assert.NoError(t, err)
+	assert.Len(t, pass, 64)
+	for _, c := range pass {
+		assert.Contains(t, symbolCharacters, string(c))
+	}
+}
+
+func TestUpdateAdminPasswordRetries(t *testing.T) {
+	users := schema.GroupResource{Group: "management.cattle.io", Resource: "users"}
+	tests := []struct {
+		name         string
+		failures     int
+		retries      int
+		wantErr      bool
+		wantAttempts int
+	}{
+		{name: "no failures", retries: 3, wantAttempts: 1},
+		{name: "succeeds after conflicts", failures: 2, retries: 3, wantAttempts: 3},
+		{name: "gives up after retries", failures: 5, retries: 3, wantErr: true, wantAttempts: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			client := fakeUsersGetter{users: &fakes.UserInterfaceMock{
+				GetFunc: func(name string, opts v1.GetOptions) (*v3.User, error) {
+					return &v3.User{ObjectMeta: v1.ObjectMeta{Name: name}}, nil
+				},
+				UpdateFunc: func(user *v3.User) (*v3.User, error) {
+					attempts++
+					if attempts <= tt.failures {
+						return nil, apierrors.NewConflict(users, user.Name, nil)
+					}
+					return user, nil
+				},
+			}}
+			opts := resetPasswordOptions{Retries: tt.retries, RetryInterval: time.Millisecond}
+
+			updated, err := updateAdminPassword(client, opts.backoff(), isRetriable, "user-abc", []byte("hash"), true)
+			assert.Equal(t, tt.wantAttempts, attempts)
+			if tt.wantErr {
+				assert.True(t, apierrors.IsConflict(err), "Expected the last conflict to be returned, got %v", err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "hash", updated.Password)
+			assert.True(t, updated.MustChangePassword)
+		})
+	}
+}
+
+func TestIsRetriable(t *testing.T) {
+	users := schema.GroupResource{Group: "management.cattle.io", Resource: "users"}
+	assert.True(t, isRetriable(apierrors.NewConflict(users, "user-abc", nil)))
+	assert.True(t, isRetriable(apierrors.NewServiceUnavailable("restarting")))
+	assert.True(t, isRetriable(apierrors.NewTooManyRequests("slow down", 1)))
+	assert.False(t, isRetriable(apierrors.NewNotFound(users, "user-abc")))
+	assert.False(t, isRetriable(apierrors.NewForbidden(users, "user-abc", nil)))
+}
+
+type fakeUsersGetter struct {
+	users normanv3.UserInterface
+}
+
+func (f fakeUsersGetter) Users(string) normanv3.UserInterface {
+	return f.users
+}
+
+func TestListAdminsWithoutUsersCRD(t *testing.T) {
+	users := schema.GroupResource{Group: "management.cattle.io", Resource: "users"}
+	tests := []struct {
+		name    string
+		listErr error
+		wantErr error
+	}{
+		{
+			name:    "no kind match",
+			listErr: &apimeta.NoKindMatchError{GroupKind: schema.GroupKind{Group: "management.cattle.io", Kind: "User"}},
+			wantErr: errRancherNotInstalled,
+		},
+		{
+			name:    "resource not found",
+			listErr: apierrors.NewNotFound(users, ""),
+			wantErr: errRancherNotInstalled,
+		},
+		{
+			name:    "other errors are passed through",
+			listErr: apierrors.NewForbidden(users, "", nil),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fakeUsersGetter{users: &fakes.UserInterfaceMock{
+				ListFunc: func(opts v1.ListOptions) (*v3.UserList, error) {
+					return nil, tt.listErr
+				},
+			}}
+			_, err := listAdmins(client, labels.Set(defaultAdminLabel))
+			if tt.wantErr != nil {
+				assert.Equal(t, tt.wantErr, err)
+				return
+			}
+			assert.Equal(t, tt.listErr, err)
+		})
+	}
+}
+
+func TestPrintResult(t *testing.T) {
+	out := resetPasswordResult{
+		adminName: "user-abc",
+		Username:  "admin",
+		Password:  "secret-password",
+		ServerURL: "https://rancher.example.com",
+		LoginURL:  "https://rancher.example.com/dashboard/auth/login",
+	}
+
+	var stdout, stderr bytes.Buffer
+	assert.NoError(t, printResult(&stdout, &stderr, "", false, out))
+	assert.Empty(t, stdout.String())
+	assert.Contains(t, stderr.String(), "secret-password")
+
+	stdout.Reset()
+	stderr.Reset()
+	assert.NoError(t, printResult(&stdout, &stderr, outputJSON, false, out))
+	assert.JSONEq(t, `{"username":"admin","password":"secret-password","serverURL":"https://rancher.example.com","loginURL":"https://rancher.example.com/dashboard/auth/login","mustChangePassword":false}`, stdout.String())
+	assert.Empty(t, stderr.String())
+
+	stdout.Reset()
+	stderr.Reset()
+	assert.NoError(t, printResult(&stdout, &stderr, outputTable, false, out))
+	assert.Contains(t, stdout.String(), "PASSWORD              secret-password")
+	assert.Contains(t, stdout.String(), "SERVER URL            https://rancher.example.com")
+	assert.Empty(t, stderr.String())
+
+	stdout.Reset()
+	stderr.Reset()
+	assert.NoError(t, printResult(&stdout, &stderr, "", true, out))
+	assert.Contains(t, stderr.String(), "secret-password", "Expected the generated password to be printed when quiet")
+
+	stderr.Reset()
+	out.Password = ""
+	assert.NoError(t, printResult(&stdout, &stderr, "", true, out))
+	assert.Empty(t, stderr.String())
+}
+
+func TestPrintAdmins(t *testing.T) {
+	admins := []v3.User{
+		{ObjectMeta: v1.ObjectMeta{Name: "user-abc"}, Username: "admin", DisplayName: "Default Admin", MustChangePassword: true},
+	}
+
+	var stdout bytes.Buffer
+	assert.NoError(t, printAdmins(&stdout, "", admins))
+	assert.Contains(t, stdout.String(), "user-abc")
+	assert.Contains(t, stdout.String(), "Default Admin")
+
+	stdout.Reset()
+	assert.NoError(t, printAdmins(&stdout, outputJSON, admins))
+	assert.JSONEq(t, `[{"name":"user-abc","username":"admin","displayName":"Default Admin","mustChangePassword":true}]`, stdout.String())
+}
+
+func TestParseAdminLabel(t *testing.T) {
+	tests := []struct {
+		name    string
+		label   string
+		want    labels.Set
+		wantErr bool
+	}{
+		{name: "default", label: "", want: labels.Set(defaultAdminLabel)},
+		{name: "key and value", label: "example.com/admin=true", want: labels.Set{"example.com/admin": "true"}},
+		{name: "empty value", label: "example.com/admin=", want: labels.Set{"example.com/admin": ""}},
+		{name: "missing separator", label: "example.com/admin", wantErr: true},
+		{name: "invalid key", label: "not a key=true", wantErr: true},
+		{name: "invalid value", label: "example.com/admin=not a value", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAdminLabel(tt.label)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestReadPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		want     string
+		wantWarn bool
+		wantErr  bool
+	}{
+		{name: "single line", content: "secret\n", want: "secret"},
+		{name: "no trailing newline", content: "secret", want: "secret"},
+		{name: "crlf", content: "secret\r\n", want: "secret"},
+		{name: "leading empty lines", content: "\n\nsecret\n", want: "secret"},
+		{name: "multiple lines", content: "2023-01-secret\n2022-12-secret\n\n2022-11-secret\n", want: "2023-01-secret", wantWarn: true},
+		{name: "empty", content: "\n\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "password")
+			assert.NoError(t, os.WriteFile(path, []byte(tt.content), 0600))
+			f, err := os.Open(path)
+			assert.NoError(t, err)
+			defer f.Close()
+
+			hook := logrustest.NewGlobal()
+			defer hook.Reset()
+
+			pass, err := readPassword(f)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(pass))
+			if tt.wantWarn {
+				assert.Equal(t, "Ignoring 2 more non-empty lines after the password read from stdin", hook.LastEntry().Message)
+			} else {
+				assert.Empty(t, hook.AllEntries())
+			}
+		})
+	}
+}
+
+func TestPromptPassword(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		wantErr bool
+	}{
+		{name: "matching", entries: []string{"secret-password", "secret-password"}},
+		{name: "mismatch", entries: []string{"secret-password", "secret-passwrod"}, wantErr: true},
+		{name: "empty", entries: []string{"", ""}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := tt.entries
+			read := func() ([]byte, error) {
+				entry := entries[0]
+				entries = entries[1:]
+				return []byte(entry), nil
+			}
+
+			var prompt bytes.Buffer
+			pass, err := promptPassword(&prompt, read)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.entries[0], string(pass))
+			assert.Contains(t, prompt.String(), "Confirm password")
+		})
+	}
+}
+
+func TestLogKubeConfigUsed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	kubeConfig := `apiVersion: v1
+kind: Config
+current-context: local
+contexts:
+- name: local
+  context:
+    cluster: local
+    user: admin
+clusters:
+- name: local
+  cluster:
+    server: https://127.0.0.1:6443
+users:
+- name: admin
+  user:
+    token: secret-token
+`
+	assert.NoError(t, os.WriteFile(path, []byte(kubeConfig), 0600))
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: path},
+		&clientcmd.ConfigOverrides{},
+	)
+
+	hook := logrustest.NewGlobal()
+	defer hook.Reset()
+
+	logKubeConfigUsed(path, "", clientConfig)
+	assert.Equal(t, "Using kubeconfig "+path+" with context local", hook.LastEntry().Message)
+	assert.NotContains(t, hook.LastEntry().Message, "secret-token")
+
+	logKubeConfigUsed(path, "other", clientConfig)
+	assert.Equal(t, "Using kubeconfig "+path+" with context other", hook.LastEntry().Message)
+
+	logKubeConfigUsed("", "", clientConfig)
+	assert.Equal(t, "Using in-cluster config", hook.LastEntry().Message)
+}
+
+func TestLoginURL(t *testing.T) {
+	tests := map[string]string{
+		"":                             "",
+		"https://rancher.example.com":  "https://rancher.example.com/dashboard/auth/login",
+		"https://rancher.example.com/": "https://rancher.example.com/dashboard/auth/login",
+		"https://10.0.0.1:8443":        "https://10.0.0.1:8443/dashboard/auth/login",
+	}
+	for serverURL, want := range tests {
+		assert.Equal(t, want, loginURL(serverURL), "unexpected login URL for server URL %q", serverURL)
+	}
+}
+
+func TestPrintServerURL(t *testing.T) {
+	var stdout bytes.Buffer
+	assert.NoError(t, printServerURL(&stdout, "", "https://rancher.example.com"))
+	assert.Equal(t, "https://rancher.example.com\n", stdout.String())
+
+	stdout.Reset()
+	assert.NoError(t, printServerURL(&stdout, outputJSON, "https://rancher.example.com"))
+	assert.JSONEq(t, `{"serverURL":"https://rancher.example.com","loginURL":"https://rancher.example.com/dashboard/auth/login"}`, stdout.String())
+
+	stdout.Reset()
+	assert.NoError(t, printServerURL(&stdout, outputJSON, ""))
+	assert.JSONEq(t, `{"serverURL":""}`, stdout.String())
+}
+
+func TestPrintAdminState(t *testing.T) {
+	state := adminStateOutput{
+		Username:           "admin",
+		MustChangePassword: true,
+		ServerURL:          "https://rancher.example.com",
+		LoginURL:           "https://rancher.example.com/dashboard/auth/login",
+	}
+
+	var stdout, stderr bytes.Buffer
+	assert.NoError(t, printAdminState(&stdout, &stderr, "", state))
+	assert.Contains(t, stdout.String(), "USERNAME              admin\n")
+	assert.Contains(t, stdout.String(), "MUST CHANGE PASSWORD  true\n")
+	assert.Contains(t, stdout.String(), "LOGIN URL             https://rancher.example.com/dashboard/auth/login\n")
+	assert.Contains(t, stderr.String(), "can't be displayed")
+
+	stdout.Reset()
+	stderr.Reset()
+	assert.NoError(t, printAdminState(&stdout, &stderr, outputJSON, state))
+	assert.JSONEq(t, `{"username":"admin","mustChangePassword":true,"serverURL":"https://rancher.example.com","loginURL":"https://rancher.example.com/dashboard/auth/login"}`, stdout.String())
+	assert.Contains(t, stderr.String(), "can't be displayed")
+}
+
+func TestWriteCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	out := resetPasswordResult{
+		adminName:          "user-abc",
+		Username:           "admin",
+		Password:           "secret",
+		ServerURL:          "https://rancher.example.com",
+		MustChangePassword: true,
+	}
+
+	assert.NoError(t, writeCredentials(path, false, out))
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"username":"admin","password":"secret","serverURL":"https://rancher.example.com","mustChangePassword":true}`, string(data))
+
+	out.Password = "rotated"
+	assert.Error(t, checkCredentialsPath(path, false))
+	err = writeCredentials(path, false, out)
+	assert.EqualError(t, err, path+" already exists, use --force to overwrite it")
+	data, err = os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"password": "secret"`, "Expected an existing file to be kept without force")
+	assert.NoError(t, writeCredentials(path, true, out))
+	data, err = os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"password": "rotated"`)
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1, "Expected no temporary files to be left behind")
+}
+
+func TestResolveKubeConfig(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first")
+	second := filepath.Join(dir, "second")
+	assert.NoError(t, os.WriteFile(first, nil, 0600))
+	assert.NoError(t, os.WriteFile(second, nil, 0600))
+	t.Setenv("HOME", dir)
+
+	// A list of paths in $KUBECONFIG is left to clientcmd.
+	list := first + string(os.PathListSeparator) + second
+	t.Setenv("KUBECONFIG", list)
+	rules, path, err := resolveKubeConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, list, path)
+	assert.Equal(t, []string{first, second}, rules.Precedence)
+
+	rules, path, err = resolveKubeConfig(first)
+	assert.NoError(t, err)
+	assert.Equal(t, first, path)
+	assert.Equal(t, first, rules.ExplicitPath)
+
+	_, _, err = resolveKubeConfig(list)
+	assert.Error(t, err, "Expected a path given with --kubeconfig to have to exist")
+
+	t.Setenv("KUBECONFIG", "")
+	rules, path, err = resolveKubeConfig("")
+	assert.NoError(t, err)
+	assert.Empty(t, path, "Expected the in-cluster config without $HOME/.kube/config")
+	assert.Empty(t, rules.ExplicitPath)
+}
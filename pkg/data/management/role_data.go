@@ -2,6 +2,7 @@
 
 import (
 	"context"
+	"net"
 	"reflect"
 	"sort"
 	"sync"
@@ -10,10 +11,12 @@
 	"github.com/pkg/errors"
 	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/features"
+	managementcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/rbac"
 	"github.com/rancher/rancher/pkg/settings"
 	"github.com/rancher/rancher/pkg/types/config"
 	"github.com/rancher/rancher/pkg/wrangler"
+	rbaccontrollers "github.com/rancher/wrangler/pkg/generated/controllers/rbac/v1"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/crypto/bcrypt"
 	corev1 "k8s.io/api/core/v1"
@@ -21,7 +24,7 @@
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/apimachinery/pkg/util/net"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
 	"k8s.io/client-go/util/retry"
 )
 
@@ -523,6 +526,11 @@ func BootstrapAdmin(management *wrangler.Context) (string, error) {
 		return "", err
 	}
 
+	var admin *v3.User
+	if len(admins.Items) > 0 {
+		admin = &admins.Items[0]
+	}
+
 	if len(users.Items) == 0 {
 		// Config map does not exist and no users, attempt to create the default admin user
 		bootstrapPassword, bootstrapPasswordIsGenerated, err := GetBootstrapPassword(context.TODO(), management.K8s.CoreV1().Secrets(cattleNamespace))
@@ -530,9 +538,12 @@ func BootstrapAdmin(management *wrangler.Context) (string, error) {
 			return "", errors.Wrap(err, "failed to retrieve bootstrap password")
 		}
 
-		bootstrapPasswordHash, _ := bcrypt.GenerateFromPassword([]byte(bootstrapPassword), bcrypt.DefaultCost)
+		bootstrapPasswordHash, err := hashBootstrapPassword(bootstrapPassword)
+		if err != nil {
+			return "", err
+		}
 
-		admin, err := management.Mgmt.User().Create(&v3.User{
+		created, err := management.Mgmt.User().Create(&v3.User{
 			ObjectMeta: v1.ObjectMeta{
 				GenerateName: "user-",
 				Labels:       defaultAdminLabel,
@@ -546,14 +557,15 @@ func BootstrapAdmin(management *wrangler.Context) (string, error) {
 			return "", errors.Wrap(err, "can not ensure admin user exists")
 		}
 		if err == nil {
+			admin = created
 			var serverURL string
 			if settings.ServerURL.Get() != "" {
 				serverURL = settings.ServerURL.Get()
 			}
 			if serverURL == "" {
-				ip, err := net.ChooseHostInterface()
+				ip, err := utilnet.ChooseHostInterface()
 				if err == nil {
-					serverURL = "https://" + ip.String()
+					serverURL = formatServerURL(ip.String())
 				}
 			}
 			if serverURL == "" {
@@ -576,53 +588,18 @@ func BootstrapAdmin(management *wrangler.Context) (string, error) {
 			logrus.Infof("-----------------------------------------")
 			logrus.Infof("")
 		}
-		adminName = admin.Name
+	} else if admin != nil {
+		// A previous run created the admin user, but was interrupted before the config map was created.
+		logrus.Infof("Resuming bootstrap of default admin user (%v)", admin.Name)
+	}
 
-		bindings, err := management.Mgmt.GlobalRoleBinding().List(v1.ListOptions{LabelSelector: set.String()})
-		if err != nil {
-			logrus.Warnf("Failed to create default admin global role binding: %v", err)
-			bindings = &v3.GlobalRoleBindingList{}
-		}
-		if len(bindings.Items) == 0 {
-			adminRole := "admin"
-			if settings.RestrictedDefaultAdmin.Get() == "true" {
-				adminRole = "restricted-admin"
-			}
-			_, err = management.Mgmt.GlobalRoleBinding().Create(
-				&v3.GlobalRoleBinding{
-					ObjectMeta: v1.ObjectMeta{
-						GenerateName: "globalrolebinding-",
-						Labels:       defaultAdminLabel,
-					},
-					UserName:       adminName,
-					GlobalRoleName: adminRole,
-				})
-			if err != nil && !features.MCM.Enabled() {
-				_, crbErr := management.RBAC.ClusterRoleBinding().Create(&rbacv1.ClusterRoleBinding{
-					ObjectMeta: v1.ObjectMeta{
-						GenerateName: "default-admin-",
-						Labels:       defaultAdminLabel,
-					},
-					Subjects: []rbacv1.Subject{{
-						Kind:     "User",
-						APIGroup: rbacv1.GroupName,
-						Name:     adminName,
-					}},
-					RoleRef: rbacv1.RoleRef{
-						APIGroup: rbacv1.GroupName,
-						Kind:     "ClusterRole",
-						Name:     "cluster-admin",
-					},
-				})
-				if crbErr != nil {
-					logrus.Warnf("Failed to create default admin global role binding: %v", err)
-				}
-			} else if err != nil {
-				logrus.Warnf("Failed to create default admin global role binding: %v", err)
-			} else {
-				logrus.Info("Created default admin user and binding")
-			}
+	if admin != nil {
+		adminName = admin.Name
+		adminRole := "admin"
+		if settings.RestrictedDefaultAdmin.Get() == "true" {
+			adminRole = "restricted-admin"
 		}
+		ensureAdminBinding(management.Mgmt.GlobalRoleBinding(), management.RBAC.ClusterRoleBinding(), admin, adminRole)
 	}
 
 	adminConfigMap := corev1.ConfigMap{
@@ -642,6 +619,144 @@ func BootstrapAdmin(management *wrangler.Context) (string, error) {
 	return adminName, nil
 }
 
+// newAdminGlobalRoleBinding returns the global role binding of the given role for the default admin user.
+func newAdminGlobalRoleBinding(admin *v3.User, adminRole string) *v3.GlobalRoleBinding {
+	return &v3.GlobalRoleBinding{
+		ObjectMeta: v1.ObjectMeta{
+			GenerateName:    "globalrolebinding-",
+			Labels:          defaultAdminLabel,
+			OwnerReferences: adminOwnerReferences(admin),
+		},
+		UserName:       admin.Name,
+		GlobalRoleName: adminRole,
+	}
+}
+
+// ensureAdminBinding binds the default admin to the admin role, falling back to cluster-admin if MCM is disabled
+// and the global role binding can't be created. Existing bindings are reused, so that a bootstrap that was
+// interrupted after some of its objects were created converges when it runs again.
+func ensureAdminBinding(grbs managementcontrollers.GlobalRoleBindingClient, crbs rbaccontrollers.ClusterRoleBindingClient, admin *v3.User, adminRole string) {
+	set := labels.Set(defaultAdminLabel)
+	bindings, err := grbs.List(v1.ListOptions{LabelSelector: set.String()})
+	if err != nil {
+		logrus.Warnf("Failed to create default admin global role binding: %v", err)
+		bindings = &v3.GlobalRoleBindingList{}
+	}
+	if !hasAdminBinding(bindings.Items, set, admin.Name, adminRole) {
+		// Bindings created by hand aren't labeled, and can't be selected by user on the server.
+		bindings, err = grbs.List(v1.ListOptions{})
+		if err != nil {
+			logrus.Warnf("Failed to create default admin global role binding: %v", err)
+			bindings = &v3.GlobalRoleBindingList{}
+		}
+	}
+	if hasAdminBinding(bindings.Items, set, admin.Name, adminRole) {
+		return
+	}
+
+	_, err = grbs.Create(newAdminGlobalRoleBinding(admin, adminRole))
+	if err != nil && !features.MCM.Enabled() {
+		crbList, listErr := crbs.List(v1.ListOptions{LabelSelector: set.String()})
+		if listErr == nil && hasAdminClusterRoleBinding(crbList.Items, admin.Name) {
+			return
+		}
+		_, crbErr := crbs.Create(&rbacv1.ClusterRoleBinding{
+			ObjectMeta: v1.ObjectMeta{
+				GenerateName:    "default-admin-",
+				Labels:          defaultAdminLabel,
+				OwnerReferences: adminOwnerReferences(admin),
+			},
+			Subjects: []rbacv1.Subject{{
+				Kind:     "User",
+				APIGroup: rbacv1.GroupName,
+				Name:     admin.Name,
+			}},
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: rbacv1.GroupName,
+				Kind:     "ClusterRole",
+				Name:     "cluster-admin",
+			},
+		})
+		if crbErr != nil {
+			logrus.Warnf("Failed to create default admin global role binding: %v", err)
+		}
+	} else if err != nil {
+		logrus.Warnf("Failed to create default admin global role binding: %v", err)
+	} else {
+		logrus.Info("Created default admin user and binding")
+	}
+}
+
+// hasAdminClusterRoleBinding returns true if one of the given bindings binds the admin user to cluster-admin.
+func hasAdminClusterRoleBinding(bindings []rbacv1.ClusterRoleBinding, adminName string) bool {
+	for _, binding := range bindings {
+		if binding.RoleRef.Kind != "ClusterRole" || binding.RoleRef.Name != "cluster-admin" {
+			continue
+		}
+		for _, subject := range binding.Subjects {
+			if subject.Kind == "User" && subject.Name == adminName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// adminOwnerReferences returns the owner references that tie a binding to the default admin user, so that the
+// binding is garbage collected with the user. There are none if the user hasn't been created yet.
+func adminOwnerReferences(admin *v3.User) []v1.OwnerReference {
+	if admin.UID == "" {
+		return nil
+	}
+	return []v1.OwnerReference{
+		{
+			APIVersion: "management.cattle.io/v3",
+			Kind:       "User",
+			Name:       admin.Name,
+			UID:        admin.UID,
+		},
+	}
+}
+
+// hasAdminBinding returns true if one of the given bindings belongs to the admin user and either carries the
+// default admin label, or binds the admin role without the label, such as a binding created by hand.
+func hasAdminBinding(bindings []v3.GlobalRoleBinding, set labels.Set, adminName, adminRole string) bool {
+	selector := set.AsSelector()
+	for _, binding := range bindings {
+		if binding.UserName != adminName {
+			continue
+		}
+		if selector.Matches(labels.Set(binding.Labels)) {
+			return true
+		}
+		if binding.GlobalRoleName == adminRole {
+			return true
+		}
+	}
+	return false
+}
+
+// hashBootstrapPassword hashes the bootstrap password for the default admin user.
+func hashBootstrapPassword(password string) ([]byte, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to hash bootstrap password")
+	}
+	return hash, nil
+}
+
+// formatServerURL returns the https URL for the given host address, wrapping IPv6 literals in square brackets.
+func formatServerURL(address string) string {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return "https://" + address
+	}
+	if ip.To4() == nil {
+		return "https://[" + ip.String() + "]"
+	}
+	return "https://" + ip.String()
+}
+
 // bootstrapDefaultRoles will set the default roles for user login, cluster create
 // and project create. If the default roles already have the bootstrappedRole
 // annotation this will be a no-op as this was done on a previous startup and will
@@ -0,0 +1,180 @@
+package management
+
+import (
+	"strings"
+	"testing"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	managementcontrollers "github.com/rancher/rancher/pkg/generated/controllers/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	rbacv1 "k8s.io/api/rbac/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestFormatServerURL(t *testing.T) {
+	tests := map[string]string{
+		"192.168.0.10":     "https://192.168.0.10",
+		"2001:db8::1":      "https://[2001:db8::1]",
+		"::ffff:10.0.0.1":  "https://10.0.0.1",
+		"rancher.internal": "https://rancher.internal",
+		"localhost":        "https://localhost",
+	}
+	for address, want := range tests {
+		assert.Equal(t, want, formatServerURL(address), "unexpected server URL for address %s", address)
+	}
+}
+
+func TestHashBootstrapPassword(t *testing.T) {
+	hash, err := hashBootstrapPassword("bootstrap-password")
+	assert.NoError(t, err)
+	assert.NoError(t, bcrypt.CompareHashAndPassword(hash, []byte("bootstrap-password")))
+
+	_, err = hashBootstrapPassword(strings.Repeat("a", 73))
+	assert.ErrorIs(t, err, bcrypt.ErrPasswordTooLong)
+}
+
+func TestHasAdminBinding(t *testing.T) {
+	set := labels.Set(defaultAdminLabel)
+	tests := []struct {
+		name     string
+		bindings []v3.GlobalRoleBinding
+		want     bool
+	}{
+		{
+			name: "no bindings",
+		},
+		{
+			name: "labeled binding",
+			bindings: []v3.GlobalRoleBinding{
+				{ObjectMeta: v1.ObjectMeta{Labels: defaultAdminLabel}, UserName: "user-abc", GlobalRoleName: "admin"},
+			},
+			want: true,
+		},
+		{
+			name: "labeled binding for another user",
+			bindings: []v3.GlobalRoleBinding{
+				{ObjectMeta: v1.ObjectMeta{Labels: defaultAdminLabel}, UserName: "user-def", GlobalRoleName: "admin"},
+			},
+		},
+		{
+			name: "unlabeled binding for the admin",
+			bindings: []v3.GlobalRoleBinding{
+				{UserName: "user-abc", GlobalRoleName: "admin"},
+			},
+			want: true,
+		},
+		{
+			name: "unlabeled binding for another user",
+			bindings: []v3.GlobalRoleBinding{
+				{UserName: "user-def", GlobalRoleName: "admin"},
+			},
+		},
+		{
+			name: "unlabeled binding to another role",
+			bindings: []v3.GlobalRoleBinding{
+				{UserName: "user-abc", GlobalRoleName: "user"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, hasAdminBinding(tt.bindings, set, "user-abc", "admin"))
+		})
+	}
+}
+
+func TestNewAdminGlobalRoleBinding(t *testing.T) {
+	admin := &v3.User{ObjectMeta: v1.ObjectMeta{Name: "user-abc", UID: "1234"}}
+
+	binding := newAdminGlobalRoleBinding(admin, "restricted-admin")
+	assert.Equal(t, "user-abc", binding.UserName)
+	assert.Equal(t, "restricted-admin", binding.GlobalRoleName)
+	assert.Equal(t, defaultAdminLabel, binding.Labels)
+	assert.Equal(t, []v1.OwnerReference{{
+		APIVersion: "management.cattle.io/v3",
+		Kind:       "User",
+		Name:       "user-abc",
+		UID:        "1234",
+	}}, binding.OwnerReferences)
+
+	binding = newAdminGlobalRoleBinding(&v3.User{ObjectMeta: v1.ObjectMeta{Name: "user-abc"}}, "admin")
+	assert.Empty(t, binding.OwnerReferences, "Expected no owner reference without the user's UID")
+}
+
+type fakeGlobalRoleBindingClient struct {
+	managementcontrollers.GlobalRoleBindingClient
+	bindings []v3.GlobalRoleBinding
+}
+
+func (f *fakeGlobalRoleBindingClient) List(opts v1.ListOptions) (*v3.GlobalRoleBindingList, error) {
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+	list := &v3.GlobalRoleBindingList{}
+	for _, binding := range f.bindings {
+		if selector.Matches(labels.Set(binding.Labels)) {
+			list.Items = append(list.Items, binding)
+		}
+	}
+	return list, nil
+}
+
+func (f *fakeGlobalRoleBindingClient) Create(binding *v3.GlobalRoleBinding) (*v3.GlobalRoleBinding, error) {
+	f.bindings = append(f.bindings, *binding)
+	return binding, nil
+}
+
+func TestEnsureAdminBindingAfterPartialRun(t *testing.T) {
+	admin := &v3.User{ObjectMeta: v1.ObjectMeta{Name: "user-abc", UID: "1234"}}
+
+	// A prior run created the admin user but was interrupted before binding it.
+	grbs := &fakeGlobalRoleBindingClient{}
+	ensureAdminBinding(grbs, nil, admin, "admin")
+	assert.Len(t, grbs.bindings, 1)
+	assert.Equal(t, "user-abc", grbs.bindings[0].UserName)
+	assert.Equal(t, "admin", grbs.bindings[0].GlobalRoleName)
+
+	// Running again converges instead of creating another binding.
+	ensureAdminBinding(grbs, nil, admin, "admin")
+	assert.Len(t, grbs.bindings, 1)
+
+	// A labeled binding left over for another user doesn't count.
+	grbs = &fakeGlobalRoleBindingClient{bindings: []v3.GlobalRoleBinding{
+		{ObjectMeta: v1.ObjectMeta{Labels: defaultAdminLabel}, UserName: "user-def", GlobalRoleName: "admin"},
+	}}
+	ensureAdminBinding(grbs, nil, admin, "admin")
+	assert.Len(t, grbs.bindings, 2)
+	assert.Equal(t, "user-abc", grbs.bindings[1].UserName)
+
+	// A binding created by hand without the label is reused as well.
+	grbs = &fakeGlobalRoleBindingClient{bindings: []v3.GlobalRoleBinding{{UserName: "user-abc", GlobalRoleName: "admin"}}}
+	ensureAdminBinding(grbs, nil, admin, "admin")
+	assert.Len(t, grbs.bindings, 1)
+}
+
+func TestHasAdminClusterRoleBinding(t *testing.T) {
+	binding := func(kind, role, user string) rbacv1.ClusterRoleBinding {
+		return rbacv1.ClusterRoleBinding{
+			Subjects: []rbacv1.Subject{{Kind: "User", APIGroup: rbacv1.GroupName, Name: user}},
+			RoleRef:  rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: kind, Name: role},
+		}
+	}
+	tests := []struct {
+		name     string
+		bindings []rbacv1.ClusterRoleBinding
+		want     bool
+	}{
+		{name: "no bindings"},
+		{name: "cluster-admin for admin", bindings: []rbacv1.ClusterRoleBinding{binding("ClusterRole", "cluster-admin", "user-abc")}, want: true},
+		{name: "cluster-admin for another user", bindings: []rbacv1.ClusterRoleBinding{binding("ClusterRole", "cluster-admin", "user-def")}},
+		{name: "other role for admin", bindings: []rbacv1.ClusterRoleBinding{binding("ClusterRole", "view", "user-abc")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, hasAdminClusterRoleBinding(tt.bindings, "user-abc"))
+		})
+	}
+}
@@ -19,7 +19,7 @@ func TestInstaller_WindowsInstallScript(t *testing.T) {
 	a := assert.New(t)
 
 	// act
-	err := settings.ServerURL.Set("localhost")
+	err := settings.ServerURL.Set("https://localhost")
 	a.Nil(err)
 
 	err = settings.CACerts.Set(CACert)
@@ -34,7 +34,7 @@ func TestInstaller_WindowsInstallScript(t *testing.T) {
 	a.NotNil(script)
 	a.Contains(string(script), "$env:CATTLE_TOKEN=\"test\"")
 	a.Contains(string(script), "$env:CATTLE_ROLE_NONE=\"true\"")
-	a.Contains(string(script), "$env:CATTLE_SERVER=\"localhost\"")
+	a.Contains(string(script), "$env:CATTLE_SERVER=\"https://localhost\"")
 	a.Contains(string(script), fmt.Sprintf("$env:CATTLE_CA_CHECKSUM=\"%s\"", CACertEncoded))
 	a.Contains(string(script), "$env:CSI_PROXY_URL")
 	a.Contains(string(script), "$env:CSI_PROXY_VERSION")
@@ -0,0 +1,104 @@
+package settings
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/rancher/rancher/pkg/namespace"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+const settingsConfigMapName = "rancher-settings"
+
+// ConfigMapProvider is a Provider that stores the values of server settings in a single config map in the
+// cattle-system namespace, keyed by setting name. It lets processes that don't run the setting controllers,
+// such as one-shot CLIs, persist settings in the cluster. Install it with SetProvider.
+type ConfigMapProvider struct {
+	configMaps corev1client.ConfigMapInterface
+	defaults   map[string]string
+}
+
+// NewConfigMapProvider will create a provider that stores settings in the cattle-system namespace.
+func NewConfigMapProvider(configMaps corev1client.ConfigMapsGetter) *ConfigMapProvider {
+	return &ConfigMapProvider{
+		configMaps: configMaps.ConfigMaps(namespace.System),
+		defaults:   map[string]string{},
+	}
+}
+
+// Get will return the stored value of the setting, or its default if no value is stored.
+func (c *ConfigMapProvider) Get(name string) string {
+	if value := os.Getenv(GetEnvKey(name)); value != "" {
+		return value
+	}
+	cm, err := c.configMaps.Get(context.TODO(), settingsConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			logrus.Errorf("failed to get config map for setting %s: %v", name, err)
+		}
+		return c.defaults[name]
+	}
+	if value := cm.Data[name]; value != "" {
+		return value
+	}
+	return c.defaults[name]
+}
+
+// Set will store the given value for the setting.
+func (c *ConfigMapProvider) Set(name, value string) error {
+	if os.Getenv(GetEnvKey(name)) != "" {
+		return fmt.Errorf("setting %s can not be set because it is from environment variable", name)
+	}
+	return c.update(func(data map[string]string) {
+		data[name] = value
+	})
+}
+
+// SetIfUnset will store the given value for the setting if no value is stored yet.
+func (c *ConfigMapProvider) SetIfUnset(name, value string) error {
+	return c.update(func(data map[string]string) {
+		if data[name] == "" {
+			data[name] = value
+		}
+	})
+}
+
+// SetAll will record the defaults of the given settings and create the config map if it doesn't exist.
+func (c *ConfigMapProvider) SetAll(settings map[string]Setting) error {
+	for name, setting := range settings {
+		c.defaults[name] = setting.Default
+	}
+	return c.update(func(map[string]string) {})
+}
+
+// update applies fn to the data of the config map, creating the config map if it doesn't exist.
+func (c *ConfigMapProvider) update(fn func(data map[string]string)) error {
+	cm, err := c.configMaps.Get(context.TODO(), settingsConfigMapName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		data := map[string]string{}
+		fn(data)
+		_, err = c.configMaps.Create(context.TODO(), &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      settingsConfigMapName,
+				Namespace: namespace.System,
+			},
+			Data: data,
+		}, metav1.CreateOptions{})
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	cm = cm.DeepCopy()
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	fn(cm.Data)
+	_, err = c.configMaps.Update(context.TODO(), cm, metav1.UpdateOptions{})
+	return err
+}
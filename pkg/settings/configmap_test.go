@@ -0,0 +1,41 @@
+package settings
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rancher/rancher/pkg/namespace"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestConfigMapProvider(t *testing.T) {
+	a := assert.New(t)
+	setting := NewSetting("test-configmap-provider", "default")
+	unset := NewSetting("test-configmap-provider-unset", "")
+
+	client := fake.NewSimpleClientset()
+	defer func() { provider = nil }()
+	a.NoError(SetProvider(NewConfigMapProvider(client.CoreV1())))
+	a.Equal("default", setting.Get())
+
+	a.NoError(setting.Set("stored"))
+	a.Equal("stored", setting.Get())
+	cm, err := client.CoreV1().ConfigMaps(namespace.System).Get(context.TODO(), settingsConfigMapName, metav1.GetOptions{})
+	a.NoError(err)
+	a.Equal("stored", cm.Data[setting.Name])
+
+	a.NoError(unset.SetIfUnset("first"))
+	a.NoError(unset.SetIfUnset("second"))
+	a.Equal("first", unset.Get())
+
+	// A new provider, as used by the next run of a CLI, reads the values back from the cluster.
+	a.NoError(SetProvider(NewConfigMapProvider(client.CoreV1())))
+	a.Equal("stored", setting.Get())
+	a.Equal("first", unset.Get())
+
+	t.Setenv(GetEnvKey(setting.Name), "from-env")
+	a.Equal("from-env", setting.Get())
+	a.Error(setting.Set("changed"))
+}
@@ -0,0 +1,113 @@
+package settings
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/rancher/rancher/pkg/namespace"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+const (
+	secretSettingPrefix = "setting-"
+	secretSettingKey    = "value"
+	redactedValue       = "[REDACTED]"
+)
+
+var (
+	secretClient     corev1client.SecretInterface
+	secretClientLock sync.RWMutex
+)
+
+// SetSecretClient will set the client used by all secret settings to store their values in the cattle-system namespace.
+func SetSecretClient(secrets corev1client.SecretsGetter) {
+	secretClientLock.Lock()
+	defer secretClientLock.Unlock()
+	secretClient = secrets.Secrets(namespace.System)
+}
+
+// currentSecretClient returns the client set with SetSecretClient, or nil if there is none.
+func currentSecretClient() corev1client.SecretInterface {
+	secretClientLock.RLock()
+	defer secretClientLock.RUnlock()
+	return secretClient
+}
+
+// SecretSetting stores a sensitive server setting in a Kubernetes secret instead of the settings provider.
+// It only supports Get, Set and SetIfUnset, and isn't registered with the other settings, so it has no default,
+// kind, validation or change notifications like Setting.
+type SecretSetting struct {
+	Name string
+}
+
+// NewSecretSetting will create a new server setting whose value is kept in a secret.
+func NewSecretSetting(name string) SecretSetting {
+	return SecretSetting{Name: name}
+}
+
+// String returns a description of the setting that never includes its value.
+func (s SecretSetting) String() string {
+	return fmt.Sprintf("%s=%s", s.Name, redactedValue)
+}
+
+// Get will return the currently stored value of the setting, or an empty string if it isn't stored.
+func (s SecretSetting) Get() string {
+	client := currentSecretClient()
+	if client == nil {
+		return ""
+	}
+	secret, err := client.Get(context.TODO(), s.secretName(), metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			logrus.Errorf("failed to get secret for setting %s: %v", s.Name, err)
+		}
+		return ""
+	}
+	return string(secret.Data[secretSettingKey])
+}
+
+// Set will store the given value for the setting.
+func (s SecretSetting) Set(value string) error {
+	client := currentSecretClient()
+	if client == nil {
+		return fmt.Errorf("failed to set setting %s: no secret client", s.Name)
+	}
+	secret, err := client.Get(context.TODO(), s.secretName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = client.Create(context.TODO(), &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      s.secretName(),
+				Namespace: namespace.System,
+			},
+			Data: map[string][]byte{secretSettingKey: []byte(value)},
+		}, metav1.CreateOptions{})
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	secret = secret.DeepCopy()
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data[secretSettingKey] = []byte(value)
+	_, err = client.Update(context.TODO(), secret, metav1.UpdateOptions{})
+	return err
+}
+
+// SetIfUnset will store the given value for the setting if no value is stored yet.
+func (s SecretSetting) SetIfUnset(value string) error {
+	if s.Get() != "" {
+		return nil
+	}
+	return s.Set(value)
+}
+
+func (s SecretSetting) secretName() string {
+	return secretSettingPrefix + s.Name
+}
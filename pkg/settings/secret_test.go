@@ -0,0 +1,34 @@
+package settings
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSecretSetting(t *testing.T) {
+	a := assert.New(t)
+	defer func() {
+		secretClientLock.Lock()
+		secretClient = nil
+		secretClientLock.Unlock()
+	}()
+	SetSecretClient(fake.NewSimpleClientset().CoreV1())
+
+	setting := NewSecretSetting("test-registry-password")
+	a.Equal("", setting.Get())
+
+	a.NoError(setting.SetIfUnset("hunter2"))
+	a.Equal("hunter2", setting.Get())
+	a.NoError(setting.SetIfUnset("ignored"))
+	a.Equal("hunter2", setting.Get())
+
+	a.NoError(setting.Set("correct-horse"))
+	a.Equal("correct-horse", setting.Get())
+
+	a.NotContains(setting.String(), "correct-horse")
+	a.NotContains(fmt.Sprintf("%v", setting), "correct-horse")
+	a.NotContains(fmt.Sprintf("%+v", setting), "correct-horse")
+}
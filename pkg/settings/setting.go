@@ -3,17 +3,27 @@
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/Masterminds/semver/v3"
+	"github.com/hashicorp/go-multierror"
 	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	authsettings "github.com/rancher/rancher/pkg/auth/settings"
 	fleetconst "github.com/rancher/rancher/pkg/fleet"
 	"github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/yaml"
 )
 
 const RancherVersionDev = "2.6.99"
@@ -21,9 +31,20 @@
 var (
 	releasePattern = regexp.MustCompile("^v[0-9]")
 	settings       = map[string]Setting{}
+	settingsLock   sync.RWMutex
 	provider       Provider
 	InjectDefaults string
 
+	observersLock sync.RWMutex
+	observers     = map[string][]func(oldValue, newValue string){}
+	watchers      = map[string]map[<-chan string]chan string{}
+
+	deprecatedSettings  = map[string]string{}
+	deprecationWarnings sync.Map
+
+	auditLock sync.RWMutex
+	auditSink AuditSink
+
 	AgentImage                          = NewSetting("agent-image", "rancher/rancher-agent:v2.6-head")
 	AgentRolloutTimeout                 = NewSetting("agent-rollout-timeout", "300s")
 	AgentRolloutWait                    = NewSetting("agent-rollout-wait", "true")
@@ -65,7 +86,7 @@
 	RkeVersion                          = NewSetting("rke-version", "")
 	RkeMetadataConfig                   = NewSetting("rke-metadata-config", getMetadataConfig())
 	ServerImage                         = NewSetting("server-image", "rancher/rancher")
-	ServerURL                           = NewSetting("server-url", "")
+	ServerURL                           = NewSetting("server-url", "").WithValidator(validateHTTPSURL)
 	ServerVersion                       = NewSetting("server-version", "dev")
 	SystemAgentVersion                  = NewSetting("system-agent-version", "")
 	WinsAgentVersion                    = NewSetting("wins-agent-version", "")
@@ -76,7 +97,7 @@
 	SystemAgentInstallerImage           = NewSetting("system-agent-installer-image", "rancher/system-agent-installer-")
 	SystemAgentUpgradeImage             = NewSetting("system-agent-upgrade-image", "")
 	WinsAgentUpgradeImage               = NewSetting("wins-agent-upgrade-image", "")
-	SystemDefaultRegistry               = NewSetting("system-default-registry", "")
+	SystemDefaultRegistry               = NewSetting("system-default-registry", "").AsReadOnly()
 	SystemNamespaces                    = NewSetting("system-namespaces", "kube-system,kube-public,cattle-system,cattle-alerting,cattle-logging,cattle-pipeline,cattle-prometheus,ingress-nginx,cattle-global-data,cattle-istio,kube-node-lease,cert-manager,cattle-global-nt,security-scan,cattle-fleet-system,cattle-fleet-local-system,calico-system,tigera-operator,cattle-impersonation-system,rancher-operator-system,cattle-csp-adapter-system,calico-apiserver")
 	SystemUpgradeControllerChartVersion = NewSetting("system-upgrade-controller-chart-version", "")
 	TelemetryOpt                        = NewSetting("telemetry-opt", "")
@@ -89,12 +110,13 @@
 	RotateCertsIfExpiringInDays         = NewSetting("rotate-certs-if-expiring-in-days", "7") // 7 days
 	ClusterTemplateEnforcement          = NewSetting("cluster-template-enforcement", "false")
 	InitialDockerRootDir                = NewSetting("initial-docker-root-dir", "/var/lib/docker")
-	SystemCatalog                       = NewSetting("system-catalog", "external") // Options are 'external' or 'bundled'
+	SystemCatalog                       = NewSetting("system-catalog", "external").WithKind(KindEnum, "external", "bundled")
 	ChartDefaultBranch                  = NewSetting("chart-default-branch", "dev-v2.6")
 	PartnerChartDefaultBranch           = NewSetting("partner-chart-default-branch", "main")
 	RKE2ChartDefaultBranch              = NewSetting("rke2-chart-default-branch", "main")
 	FleetDefaultWorkspaceName           = NewSetting("fleet-default-workspace-name", fleetconst.ClustersDefaultNamespace) // fleetWorkspaceName to assign to clusters with none
 	ShellImage                          = NewSetting("shell-image", "rancher/shell:v0.1.20-rc1")
+	ShellImageDigest                    = NewSetting("shell-image-digest", "")
 	IgnoreNodeName                      = NewSetting("ignore-node-name", "") // nodes to ignore when syncing v1.node to v3.node
 	NoDefaultAdmin                      = NewSetting("no-default-admin", "")
 	RestrictedDefaultAdmin              = NewSetting("restricted-default-admin", "false") // When bootstrapping the admin for the first time, give them the global role restricted-admin
@@ -103,8 +125,9 @@
 	EKSUpstreamRefresh                  = NewSetting("eks-refresh", "300")
 	GKEUpstreamRefresh                  = NewSetting("gke-refresh", "300")
 	HideLocalCluster                    = NewSetting("hide-local-cluster", "false")
+	DisableAllFeatures                  = NewSetting("disable-all-features", "false").WithKind(KindBool) // Kill switch that turns off every setting checked with IsFeatureEnabled
 	MachineProvisionImage               = NewSetting("machine-provision-image", "rancher/machine:v0.15.0-rancher96")
-	SystemFeatureChartRefreshSeconds    = NewSetting("system-feature-chart-refresh-seconds", "900")
+	SystemFeatureChartRefreshSeconds    = NewSetting("system-feature-chart-refresh-seconds", "900").WithKind(KindInt)
 
 	Rke2DefaultVersion = NewSetting("rke2-default-version", "")
 	K3sDefaultVersion  = NewSetting("k3s-default-version", "")
@@ -199,9 +222,40 @@
 	UIPreferred = NewSetting("ui-preferred", "vue")
 )
 
-// FullShellImage returns the full private registry name of the rancher shell image.
+// FullShellImage returns the full private registry name of the rancher shell image, see ResolveImage.
+// If ShellImageDigest is set, the image is pinned to that digest instead of its tag.
 func FullShellImage() string {
-	return PrefixPrivateRegistry(ShellImage.Get())
+	image := ResolveImage(ShellImage.Get())
+	if digest := ShellImageDigest.Get(); digest != "" {
+		image = pinDigest(image, digest)
+	}
+	return image
+}
+
+// pinDigest returns the given image referenced by digest, stripping any tag or digest it already has.
+func pinDigest(image, digest string) string {
+	image, _, _ = strings.Cut(image, "@")
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image + "@" + strings.TrimPrefix(digest, "@")
+}
+
+// ResolveImage returns the full private registry name of the given image.
+// If the image is already qualified with a registry host, that host is replaced by the stored private registry.
+func ResolveImage(image string) string {
+	if SystemDefaultRegistry.Get() != "" {
+		if host, rest, ok := strings.Cut(image, "/"); ok && isRegistryHost(host) {
+			image = rest
+		}
+	}
+	return PrefixPrivateRegistry(image)
+}
+
+// isRegistryHost returns true if the first path segment of an image name refers to a registry host
+// rather than a repository namespace, following the same rules as the docker reference parser.
+func isRegistryHost(segment string) bool {
+	return strings.ContainsAny(segment, ".:") || segment == "localhost"
 }
 
 // PrefixPrivateRegistry prefixes the given image name with the stored private registry path.
@@ -214,8 +268,11 @@ func PrefixPrivateRegistry(image string) string {
 }
 
 // IsRelease returns true if the running server is a released version of rancher.
+// Pre-release versions such as release candidates (v2.7.0-rc1) are not releases,
+// while build metadata (v2.7.0+build5) does not disqualify a release.
 func IsRelease() bool {
-	return !strings.Contains(ServerVersion.Get(), "head") && releasePattern.MatchString(ServerVersion.Get())
+	version, _, _ := strings.Cut(ServerVersion.Get(), "+")
+	return !strings.Contains(version, "head") && !strings.Contains(version, "-") && releasePattern.MatchString(version)
 }
 
 func init() {
@@ -225,23 +282,52 @@ func init() {
 	authsettings.AuthUserInfoMaxAgeSeconds = AuthUserInfoMaxAgeSeconds
 	authsettings.FirstLogin = FirstLogin
 
-	if InjectDefaults == "" {
+	injectDefaults(InjectDefaults)
+}
+
+// injectDefaults overrides the defaults of registered settings with the values in the given JSON object.
+func injectDefaults(data string) {
+	if data == "" {
 		return
 	}
 	defaults := map[string]string{}
-	if err := json.Unmarshal([]byte(InjectDefaults), &defaults); err != nil {
+	if err := json.Unmarshal([]byte(data), &defaults); err != nil {
 		return
 	}
 	for name, defaultValue := range defaults {
-		value, ok := settings[name]
-		if !ok {
-			continue
-		}
-		value.Default = defaultValue
-		settings[name] = value
+		update(name, func(value *Setting) {
+			value.Default = defaultValue
+			value.defaultValue = defaultValue
+			value.source = SourceInjected
+		})
 	}
 }
 
+// LoadDefaults overrides the defaults of registered settings with the values in the given JSON or YAML file,
+// which maps setting names to values. The defaults take the same precedence as InjectDefaults, so values
+// stored with Set still win. It should be called before SetProvider, which reads the defaults.
+func LoadDefaults(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read setting defaults: %w", err)
+	}
+	defaults := map[string]string{}
+	if err := yaml.Unmarshal(data, &defaults); err != nil {
+		return fmt.Errorf("failed to parse setting defaults from %s: %w", path, err)
+	}
+	for name, defaultValue := range defaults {
+		update(name, func(value *Setting) {
+			// Without a provider, Set stores the value in Default, which has to be kept.
+			if !value.custom {
+				value.Default = defaultValue
+			}
+			value.defaultValue = defaultValue
+			value.source = SourceInjected
+		})
+	}
+	return nil
+}
+
 // Provider is an interfaced used to get and set Settings.
 type Provider interface {
 	Get(name string) string
@@ -250,15 +336,178 @@ type Provider interface {
 	SetAll(settings map[string]Setting) error
 }
 
+// SettingSource describes where the current value of a setting comes from.
+type SettingSource string
+
+const (
+	// SourceDefault is the default the setting was declared with.
+	SourceDefault SettingSource = "default"
+	// SourceEnv is the CATTLE_* environment variable of the setting.
+	SourceEnv SettingSource = "env"
+	// SourceInjected is the build-time InjectDefaults map.
+	SourceInjected SettingSource = "injected"
+	// SourceCustom is a value stored with Set.
+	SourceCustom SettingSource = "custom"
+)
+
+// SettingKind describes the type of the values of a setting, such as for generating an editor for it.
+type SettingKind string
+
+const (
+	KindString SettingKind = "string"
+	KindInt    SettingKind = "int"
+	KindBool   SettingKind = "bool"
+	// KindEnum settings only take one of the Options of the setting.
+	KindEnum SettingKind = "enum"
+)
+
+var (
+	// ErrReadOnly is matched by errors from changing a read-only setting, see AsReadOnly.
+	ErrReadOnly = errors.New("setting is read-only")
+	// ErrValidation is matched by errors from a value rejected by the validator of a setting, see WithValidator.
+	ErrValidation = errors.New("invalid setting value")
+	// ErrUnknownSetting is matched by errors about a setting name that isn't registered, such as from Apply.
+	ErrUnknownSetting = errors.New("unknown setting")
+)
+
+// SettingError is a failure concerning a specific setting. It matches its Kind, one of ErrReadOnly,
+// ErrValidation or ErrUnknownSetting, with errors.Is and wraps the underlying error, if any.
+type SettingError struct {
+	Name string
+	Kind error
+	Err  error
+}
+
+func (e *SettingError) Error() string {
+	switch e.Kind {
+	case ErrReadOnly:
+		return fmt.Sprintf("setting %s is read-only", e.Name)
+	case ErrValidation:
+		return fmt.Sprintf("invalid value for setting %s: %v", e.Name, e.Err)
+	case ErrUnknownSetting:
+		return fmt.Sprintf("unknown setting %s", e.Name)
+	}
+	if e.Err != nil {
+		return fmt.Sprintf("setting %s: %v", e.Name, e.Err)
+	}
+	return fmt.Sprintf("setting %s: %v", e.Name, e.Kind)
+}
+
+func (e *SettingError) Unwrap() error {
+	return e.Err
+}
+
+func (e *SettingError) Is(target error) bool {
+	return target == e.Kind
+}
+
 // Setting stores information about a specific server setting.
 type Setting struct {
-	Name     string
-	Default  string
-	ReadOnly bool
+	Name      string
+	Default   string
+	ReadOnly  bool
+	Kind      SettingKind
+	Options   []string
+	validator func(string) error
+	// defaultValue and source describe the resolved default, which Set overwrites in Default until a provider is set.
+	defaultValue string
+	source       SettingSource
+	custom       bool
+}
+
+// Source will return where the currently stored value of the setting comes from,
+// following the same precedence as Get.
+func (s Setting) Source() SettingSource {
+	s = s.resolve()
+	stored, _ := ByName(s.Name)
+	if os.Getenv(GetEnvKey(s.Name)) != "" {
+		return SourceEnv
+	}
+	if provider := currentProvider(); provider != nil {
+		if provider.Get(s.Name) != stored.Default {
+			return SourceCustom
+		}
+	}
+	if stored.custom {
+		return SourceCustom
+	}
+	if stored.source == "" {
+		return SourceDefault
+	}
+	return stored.source
+}
+
+// WithKind sets the type of the values of the setting and, for KindEnum, the allowed values.
+func (s Setting) WithKind(kind SettingKind, options ...string) Setting {
+	s.Kind = kind
+	s.Options = options
+	update(s.Name, func(stored *Setting) {
+		stored.Kind = kind
+		stored.Options = options
+	})
+	return s
+}
+
+// Metadata will return the type of the values of the setting and its allowed values, see WithKind.
+// Settings without a kind are strings.
+func (s Setting) Metadata() (SettingKind, []string) {
+	s = s.resolve()
+	stored, _ := ByName(s.Name)
+	if stored.Kind == "" {
+		return KindString, nil
+	}
+	return stored.Kind, stored.Options
+}
+
+// WithValidator registers a function that every value must pass before it is stored for the setting.
+func (s Setting) WithValidator(validator func(string) error) Setting {
+	s.validator = validator
+	update(s.Name, func(stored *Setting) {
+		stored.validator = validator
+	})
+	return s
+}
+
+// validateHTTPSURL accepts an empty value or an absolute URL with the https scheme.
+func validateHTTPSURL(value string) error {
+	if value == "" {
+		return nil
+	}
+	u, err := url.Parse(value)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		return fmt.Errorf("%q is not an absolute URL", value)
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("%q must use the https scheme", value)
+	}
+	return nil
+}
+
+// Validate will return an error if the given value is rejected by the setting's validator.
+func (s Setting) Validate(value string) error {
+	validator := s.validator
+	if stored, ok := ByName(s.Name); ok && stored.validator != nil {
+		validator = stored.validator
+	}
+	if validator == nil {
+		return nil
+	}
+	if err := validator(value); err != nil {
+		return &SettingError{Name: s.Name, Kind: ErrValidation, Err: err}
+	}
+	return nil
 }
 
 // SetIfUnset will store the given value of the setting if it was not already stored.
 func (s Setting) SetIfUnset(value string) error {
+	s = s.resolve()
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	if err := s.Validate(value); err != nil {
+		return err
+	}
+	provider := currentProvider()
 	if provider == nil {
 		return s.Set(value)
 	}
@@ -267,25 +516,232 @@ func (s Setting) SetIfUnset(value string) error {
 
 // Set will store the given value for the setting
 func (s Setting) Set(value string) error {
-	if provider == nil {
-		s, ok := settings[s.Name]
-		if ok {
-			s.Default = value
-			settings[s.Name] = s
-		}
-	} else {
-		return provider.Set(s.Name, value)
+	s = s.resolve()
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	if err := s.Validate(value); err != nil {
+		return err
 	}
+	return s.setInternal(value)
+}
+
+// setInternal stores the given value without the read-only and validation checks of Set. It is for code in this
+// package that owns the value of a read-only setting. Defaults injection (InjectDefaults and LoadDefaults) doesn't
+// use it: it replaces defaults rather than values, so it isn't blocked by read-only in the first place.
+func (s Setting) setInternal(value string) error {
+	oldValue := s.Get()
+	if provider := currentProvider(); provider == nil {
+		update(s.Name, func(stored *Setting) {
+			stored.Default = value
+			stored.custom = true
+		})
+	} else if err := provider.Set(s.Name, value); err != nil {
+		return err
+	}
+	s.audit(oldValue, value)
+	s.notify(oldValue, value)
 	return nil
 }
 
+// AsReadOnly marks the setting as read-only, so that it can't be changed at runtime with Set, SetIfUnset or Reset.
+// This only guards in-process writes: its default can still be overridden by InjectDefaults, LoadDefaults and the
+// environment.
+func (s Setting) AsReadOnly() Setting {
+	s.ReadOnly = true
+	update(s.Name, func(stored *Setting) {
+		stored.ReadOnly = true
+	})
+	return s
+}
+
+func (s Setting) checkWritable() error {
+	if stored, _ := ByName(s.Name); s.ReadOnly || stored.ReadOnly {
+		return &SettingError{Name: s.Name, Kind: ErrReadOnly}
+	}
+	return nil
+}
+
+// Reset will clear the stored value of the setting so that Get falls back to its default.
+// The value the setting reverted to is returned.
+func (s Setting) Reset() (string, error) {
+	s = s.resolve()
+	if err := s.checkWritable(); err != nil {
+		return "", err
+	}
+	oldValue := s.Get()
+	if provider := currentProvider(); provider == nil {
+		ok := update(s.Name, func(stored *Setting) {
+			stored.Default = stored.defaultValue
+			stored.custom = false
+		})
+		if !ok {
+			return "", nil
+		}
+	} else if err := provider.Set(s.Name, ""); err != nil {
+		return "", err
+	}
+	value := s.Get()
+	s.audit(oldValue, value)
+	s.notify(oldValue, value)
+	return value, nil
+}
+
+// AuditSink records changes made to settings through Set, SetIfUnset and Reset.
+type AuditSink interface {
+	Record(name, oldValue, newValue string, changedAt time.Time)
+}
+
+// SetAuditSink sets the sink that setting changes are reported to. A nil sink disables auditing.
+func SetAuditSink(sink AuditSink) {
+	auditLock.Lock()
+	defer auditLock.Unlock()
+	auditSink = sink
+}
+
+func (s Setting) audit(oldValue, newValue string) {
+	auditLock.RLock()
+	sink := auditSink
+	auditLock.RUnlock()
+	if sink != nil {
+		sink.Record(s.Name, oldValue, newValue, time.Now())
+	}
+}
+
+// OnChange registers a callback that is invoked after the setting is stored with a different value.
+func (s Setting) OnChange(callback func(oldValue, newValue string)) {
+	s = s.resolve()
+	observersLock.Lock()
+	defer observersLock.Unlock()
+	observers[s.Name] = append(observers[s.Name], callback)
+}
+
+// Watch returns a channel that receives the new value of the setting every time it is stored with a different
+// value, until the channel is passed to StopWatch. Sends never block: if the receiver falls behind, only the
+// latest value is kept.
+func (s Setting) Watch() <-chan string {
+	s = s.resolve()
+	ch := make(chan string, 1)
+	observersLock.Lock()
+	defer observersLock.Unlock()
+	if watchers[s.Name] == nil {
+		watchers[s.Name] = map[<-chan string]chan string{}
+	}
+	watchers[s.Name][ch] = ch
+	return ch
+}
+
+// StopWatch stops delivering changes to a channel returned by Watch and closes it.
+func (s Setting) StopWatch(watch <-chan string) {
+	s = s.resolve()
+	observersLock.Lock()
+	defer observersLock.Unlock()
+	if ch, ok := watchers[s.Name][watch]; ok {
+		delete(watchers[s.Name], watch)
+		close(ch)
+	}
+}
+
+// notify invokes the callbacks and feeds the watches registered for the setting if the value changed.
+// A panicking callback is logged and does not prevent the remaining callbacks from running.
+func (s Setting) notify(oldValue, newValue string) {
+	if oldValue == newValue {
+		return
+	}
+	observersLock.RLock()
+	callbacks := append([]func(string, string){}, observers[s.Name]...)
+	for _, ch := range watchers[s.Name] {
+		// Drop a value that hasn't been received yet in favor of the new one.
+		select {
+		case <-ch:
+		default:
+		}
+		select {
+		case ch <- newValue:
+		default:
+		}
+	}
+	observersLock.RUnlock()
+
+	for _, callback := range callbacks {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					logrus.Errorf("observer of setting %s panicked: %v", s.Name, r)
+				}
+			}()
+			callback(oldValue, newValue)
+		}()
+	}
+}
+
 // Get will return the currently stored value of the setting.
+// The CATTLE_* environment variable of the setting, see GetEnvKey, takes precedence over the stored value.
 func (s Setting) Get() string {
-	if provider == nil {
-		s := settings[s.Name]
+	s = s.resolve()
+	if value := os.Getenv(GetEnvKey(s.Name)); value != "" {
+		return value
+	}
+	stored, _ := ByName(s.Name)
+	value := stored.Default
+	if provider := currentProvider(); provider != nil {
+		value = provider.Get(s.Name)
+	}
+	return value
+}
+
+// Changed will return true if the current value of the setting, see Get, differs from its resolved default,
+// which is the injected or declared default.
+func (s Setting) Changed() bool {
+	s = s.resolve()
+	stored, _ := ByName(s.Name)
+	return s.Get() != stored.defaultValue
+}
+
+// GetOneOf will return the currently stored value of the setting if it is one of the allowed values.
+// Otherwise the default value is returned if it is allowed, and failing that the first allowed value.
+func (s Setting) GetOneOf(allowed ...string) string {
+	v := s.Get()
+	if contains(allowed, v) {
+		return v
+	}
+	logrus.Errorf("setting %s=%s is not one of %v", s.Name, v, allowed)
+	if contains(allowed, s.Default) {
 		return s.Default
 	}
-	return provider.Get(s.Name)
+	if len(allowed) == 0 {
+		return ""
+	}
+	return allowed[0]
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRaw will return the value that was explicitly stored for the setting and whether there is one,
+// without falling back to the environment or the default. An explicitly stored empty value is reported as set.
+// With a provider, a stored value can only be told apart from the default when the two differ.
+func (s Setting) GetRaw() (string, bool) {
+	s = s.resolve()
+	stored, _ := ByName(s.Name)
+	provider := currentProvider()
+	if provider == nil {
+		if !stored.custom {
+			return "", false
+		}
+		return stored.Default, true
+	}
+	value := provider.Get(s.Name)
+	if value == stored.Default || value == os.Getenv(GetEnvKey(s.Name)) {
+		return "", false
+	}
+	return value, true
 }
 
 // GetInt will return the currently stored value of the setting as an integer.
@@ -305,28 +761,601 @@ func (s Setting) GetInt() int {
 	return i
 }
 
+// GetIntOr will return the currently stored value of the setting as an integer.
+// If the stored value is not an integer then fallback will be returned, regardless of the default value.
+func (s Setting) GetIntOr(fallback int) int {
+	v := s.Get()
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		logrus.Errorf("failed to parse setting %s=%s as int: %v", s.Name, v, err)
+		return fallback
+	}
+	return i
+}
+
+// GetInt64 will return the currently stored value of the setting as a 64-bit integer, for values such as byte
+// counts that may not fit an int on 32-bit platforms.
+// If the stored value is not an integer then the default value will be returned as an integer.
+// If the default value is not an integer then the function will return 0
+func (s Setting) GetInt64() int64 {
+	v := s.Get()
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err == nil {
+		return i
+	}
+	logrus.Errorf("failed to parse setting %s=%s as int64: %v", s.Name, v, err)
+	i, err = strconv.ParseInt(s.Default, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return i
+}
+
+// MustGet will return the currently stored value of the setting, see Get, and panic if it is empty.
+// It is meant for initialization code where a missing setting is a programming error.
+func (s Setting) MustGet() string {
+	value := s.Get()
+	if value == "" {
+		panic(fmt.Sprintf("setting %s has no value and no default", s.Name))
+	}
+	return value
+}
+
+// MustGetInt will return the currently stored value of the setting as an integer, see MustGet,
+// and panic if neither the value nor the default is an integer.
+func (s Setting) MustGetInt() int {
+	value := s.MustGet()
+	if i, err := strconv.Atoi(value); err == nil {
+		return i
+	}
+	i, err := strconv.Atoi(s.Default)
+	if err != nil {
+		panic(fmt.Sprintf("setting %s=%s is not an integer", s.Name, value))
+	}
+	return i
+}
+
+// GetIntClamped will return the currently stored value of the setting as an integer, see GetInt,
+// limited to the inclusive range between minimum and maximum.
+func (s Setting) GetIntClamped(minimum, maximum int) int {
+	i := s.GetInt()
+	if i < minimum {
+		return minimum
+	}
+	if i > maximum {
+		return maximum
+	}
+	return i
+}
+
+// GetFloat will return the currently stored value of the setting as a float.
+// If the stored value is not a float then the default value will be returned as a float.
+// If the default value is not a float then the function will return 0
+func (s Setting) GetFloat() float64 {
+	v := s.Get()
+	f, err := strconv.ParseFloat(v, 64)
+	if err == nil {
+		return f
+	}
+	logrus.Errorf("failed to parse setting %s=%s as float: %v", s.Name, v, err)
+	f, err = strconv.ParseFloat(s.Default, 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
+// GetBool will return the currently stored value of the setting as a boolean.
+// In addition to the values accepted by strconv.ParseBool, "yes" and "on" are treated as true.
+// If the stored value is not a boolean then the default value will be returned as a boolean.
+// If the default value is not a boolean then the function will return false
+func (s Setting) GetBool() bool {
+	v := s.Get()
+	b, err := parseBool(v)
+	if err == nil {
+		return b
+	}
+	logrus.Errorf("failed to parse setting %s=%s as bool: %v", s.Name, v, err)
+	b, err = parseBool(s.Default)
+	if err != nil {
+		return false
+	}
+	return b
+}
+
+// IsFeatureEnabled will return true if the setting, treated as a feature flag, is turned on, see GetBool.
+// The DisableAllFeatures kill switch takes precedence and turns every feature off.
+func (s Setting) IsFeatureEnabled() bool {
+	if s.Name != DisableAllFeatures.Name && DisableAllFeatures.GetBool() {
+		return false
+	}
+	return s.GetBool()
+}
+
+func parseBool(value string) (bool, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case "yes", "on":
+		return true, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// GetDuration will return the currently stored value of the setting as a time.Duration.
+// The value is parsed as a duration string (e.g. "6h") and, failing that, as an integer number of seconds.
+// If the stored value is not a duration then the default value will be returned as a duration.
+// If the default value is not a duration then the function will return 0
+func (s Setting) GetDuration() time.Duration {
+	v := s.Get()
+	d, err := parseDuration(v)
+	if err == nil {
+		return d
+	}
+	logrus.Errorf("failed to parse setting %s=%s as duration: %v", s.Name, v, err)
+	d, err = parseDuration(s.Default)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// GetSeconds will return the currently stored value of the setting as a non-negative integer number of seconds.
+// If the stored value is not a valid number of seconds then the default value will be used instead,
+// and if neither is valid the function will return 0.
+func (s Setting) GetSeconds() int {
+	v := s.Get()
+	i, err := strconv.Atoi(v)
+	if err == nil && i >= 0 {
+		return i
+	}
+	logrus.Errorf("failed to parse setting %s=%s as seconds", s.Name, v)
+	i, err = strconv.Atoi(s.Default)
+	if err != nil || i < 0 {
+		return 0
+	}
+	return i
+}
+
+func parseDuration(value string) (time.Duration, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return d, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return time.Duration(i) * time.Second, nil
+}
+
+// GetSlice will return the currently stored value of the setting as a slice of strings.
+// The value is split on commas, each element is trimmed of surrounding whitespace and empty elements are dropped.
+// If the stored value is empty then the default value will be returned as a slice.
+func (s Setting) GetSlice() []string {
+	v := s.Get()
+	if strings.TrimSpace(v) == "" {
+		v = s.Default
+	}
+	return splitList(v)
+}
+
+// GetIntSlice will return the currently stored value of the setting as a slice of integers, see GetSlice.
+// Elements that are not integers are logged and skipped.
+func (s Setting) GetIntSlice() []int {
+	var result []int
+	for _, item := range s.GetSlice() {
+		i, err := strconv.Atoi(item)
+		if err != nil {
+			logrus.Errorf("failed to parse element %s of setting %s as int: %v", item, s.Name, err)
+			continue
+		}
+		result = append(result, i)
+	}
+	return result
+}
+
+// GetSliceMerged will return the default value of the setting as a slice, see GetSlice, followed by the elements of
+// the currently stored value that aren't already in it. This lets a stored value add to the defaults rather than replace them.
+func (s Setting) GetSliceMerged() []string {
+	defaultValue := s.defaultValue
+	if stored, ok := ByName(s.Name); ok {
+		defaultValue = stored.defaultValue
+	}
+
+	var result []string
+	seen := map[string]bool{}
+	for _, item := range append(splitList(defaultValue), splitList(s.Get())...) {
+		if seen[item] {
+			continue
+		}
+		seen[item] = true
+		result = append(result, item)
+	}
+	return result
+}
+
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+// GetMap will return the currently stored value of the setting as a map, parsing the format k1=v1,k2=v2.
+// Keys and values are trimmed of surrounding whitespace and pairs without a key or an equals sign are skipped.
+// If the stored value is empty then the default value will be returned as a map.
+func (s Setting) GetMap() map[string]string {
+	v := s.Get()
+	if strings.TrimSpace(v) == "" {
+		v = s.Default
+	}
+	result := map[string]string{}
+	for _, pair := range splitList(v) {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			logrus.Warnf("skipping malformed pair %q in setting %s", pair, s.Name)
+			continue
+		}
+		result[key] = strings.TrimSpace(value)
+	}
+	return result
+}
+
+// GetJSON will unmarshal the currently stored value of the setting into the given target.
+// If the stored value is empty then the default value will be unmarshalled instead.
+func (s Setting) GetJSON(target interface{}) error {
+	v := s.Get()
+	if strings.TrimSpace(v) == "" {
+		v = s.Default
+	}
+	if err := json.Unmarshal([]byte(v), target); err != nil {
+		return fmt.Errorf("failed to parse setting %s as json: %w", s.Name, err)
+	}
+	return nil
+}
+
+// GetBytes will return the currently stored value of the setting as a number of bytes, parsing a Kubernetes
+// quantity such as 512Mi or 2G. If the stored value is not a quantity then the default value will be used instead,
+// and an error is returned if the default value is not a quantity either.
+func (s Setting) GetBytes() (int64, error) {
+	v := s.Get()
+	q, err := resource.ParseQuantity(v)
+	if err == nil {
+		return q.Value(), nil
+	}
+	logrus.Errorf("failed to parse setting %s=%s as quantity: %v", s.Name, v, err)
+	q, err = resource.ParseQuantity(s.Default)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse setting %s as quantity: %w", s.Name, err)
+	}
+	return q.Value(), nil
+}
+
+// GetURL will return the currently stored value of the setting parsed as a URL.
+// If the stored value is empty then the default value will be parsed instead.
+func (s Setting) GetURL() (*url.URL, error) {
+	v := s.Get()
+	if strings.TrimSpace(v) == "" {
+		v = s.Default
+	}
+	u, err := url.Parse(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse setting %s as url: %w", s.Name, err)
+	}
+	return u, nil
+}
+
 // SetProvider will set the given provider as the global provider for all settings
 func SetProvider(p Provider) error {
-	if err := p.SetAll(settings); err != nil {
+	settingsLock.RLock()
+	registered := make(map[string]Setting, len(settings))
+	for name, s := range settings {
+		registered[name] = s
+	}
+	settingsLock.RUnlock()
+
+	if err := p.SetAll(registered); err != nil {
 		return err
 	}
+	settingsLock.Lock()
+	defer settingsLock.Unlock()
 	provider = p
 	return nil
 }
 
+// currentProvider returns the provider set with SetProvider, or nil if there is none.
+func currentProvider() Provider {
+	settingsLock.RLock()
+	defer settingsLock.RUnlock()
+	return provider
+}
+
+// update applies fn to the registered setting with the given name and returns whether there is one.
+func update(name string, fn func(*Setting)) bool {
+	settingsLock.Lock()
+	defer settingsLock.Unlock()
+	s, ok := settings[name]
+	if !ok {
+		return false
+	}
+	fn(&s)
+	settings[name] = s
+	return true
+}
+
 // NewSetting will create and store a new server setting.
 func NewSetting(name, def string) Setting {
 	s := Setting{
-		Name:    name,
-		Default: def,
+		Name:         name,
+		Default:      def,
+		defaultValue: def,
 	}
+	settingsLock.Lock()
+	defer settingsLock.Unlock()
 	settings[s.Name] = s
 	return s
 }
 
-// GetEnvKey will return the given string formatted as a rancher environmental variable
+// RegisterStruct will register a setting for every field of the struct v that has a `setting:"name,default"`
+// tag and return the registered settings keyed by field name. Everything after the first comma is the default,
+// so defaults may contain commas. If v is a pointer, fields of type Setting are assigned the registered setting.
+// It panics if v is not a struct or a pointer to one, or if a tag has no name.
+func RegisterStruct(v interface{}) map[string]*Setting {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("settings: RegisterStruct needs a struct, got %T", v))
+	}
+	registered := map[string]*Setting{}
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Type().Field(i)
+		tag, ok := field.Tag.Lookup("setting")
+		if !ok {
+			continue
+		}
+		name, def, _ := strings.Cut(tag, ",")
+		if name == "" {
+			panic(fmt.Sprintf("settings: field %s has a setting tag without a name", field.Name))
+		}
+		s := NewSetting(name, def)
+		if f := rv.Field(i); f.CanSet() && f.Type() == reflect.TypeOf(s) {
+			f.Set(reflect.ValueOf(s))
+		}
+		registered[field.Name] = &s
+	}
+	return registered
+}
+
+// All will return every registered setting sorted by name.
+func All() []Setting {
+	settingsLock.RLock()
+	result := make([]Setting, 0, len(settings))
+	for _, s := range settings {
+		result = append(result, s)
+	}
+	settingsLock.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// ByName will return the registered setting with the given name.
+func ByName(name string) (Setting, bool) {
+	settingsLock.RLock()
+	defer settingsLock.RUnlock()
+	s, ok := settings[name]
+	return s, ok
+}
+
+// Lint will return a description of every registered setting whose current value, see Get, is inconsistent
+// with its kind, see WithKind, sorted by setting name. Empty values are not reported.
+func Lint() []string {
+	var problems []string
+	for _, s := range All() {
+		kind, options := s.Metadata()
+		value := s.Get()
+		if value == "" {
+			continue
+		}
+		switch kind {
+		case KindInt:
+			if _, err := strconv.Atoi(value); err != nil {
+				problems = append(problems, fmt.Sprintf("setting %s=%s is not an int", s.Name, value))
+			}
+		case KindBool:
+			if _, err := parseBool(value); err != nil {
+				problems = append(problems, fmt.Sprintf("setting %s=%s is not a bool", s.Name, value))
+			}
+		case KindEnum:
+			if !contains(options, value) {
+				problems = append(problems, fmt.Sprintf("setting %s=%s is not one of %v", s.Name, value, options))
+			}
+		}
+	}
+	return problems
+}
+
+// SettingValue is a snapshot of a setting's value and where it comes from.
+type SettingValue struct {
+	Name    string        `json:"name"`
+	Value   string        `json:"value"`
+	Default string        `json:"default"`
+	Source  SettingSource `json:"source"`
+}
+
+// Export will return a snapshot of the registered settings sorted by name.
+// Unless all is true, only settings whose value differs from their default are included.
+func Export(all bool) []SettingValue {
+	var result []SettingValue
+	for _, s := range All() {
+		value := SettingValue{
+			Name:    s.Name,
+			Value:   s.Get(),
+			Default: s.defaultValue,
+			Source:  s.Source(),
+		}
+		if _, isSet := s.GetRaw(); !all && !isSet && value.Value == value.Default {
+			continue
+		}
+		result = append(result, value)
+	}
+	return result
+}
+
+// Apply will validate and store each of the given setting values, such as a snapshot produced by Export.
+// All settings are attempted and the failures are returned together. Unknown setting names are
+// reported as errors unless ignoreUnknown is true.
+func Apply(values map[string]string, ignoreUnknown bool) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var result error
+	for _, name := range names {
+		s, ok := ByName(name)
+		if !ok {
+			if !ignoreUnknown {
+				result = multierror.Append(result, &SettingError{Name: name, Kind: ErrUnknownSetting})
+			}
+			continue
+		}
+		if err := s.Set(values[name]); err != nil {
+			result = multierror.Append(result, fmt.Errorf("failed to set %s: %w", name, err))
+		}
+	}
+	return result
+}
+
+// DiffType describes how a setting differs between two snapshots.
+type DiffType string
+
+const (
+	DiffAdded   DiffType = "added"
+	DiffRemoved DiffType = "removed"
+	DiffChanged DiffType = "changed"
+)
+
+// SettingDiff is a setting that differs between two snapshots, see Diff.
+type SettingDiff struct {
+	Name     string   `json:"name"`
+	Type     DiffType `json:"type"`
+	OldValue string   `json:"oldValue,omitempty"`
+	NewValue string   `json:"newValue,omitempty"`
+}
+
+// Diff will return the settings that were added, removed or changed going from snapshot a to snapshot b,
+// such as two name to value maps built from Export, sorted by name.
+func Diff(a, b map[string]string) []SettingDiff {
+	var result []SettingDiff
+	for name, oldValue := range a {
+		newValue, ok := b[name]
+		if !ok {
+			result = append(result, SettingDiff{Name: name, Type: DiffRemoved, OldValue: oldValue})
+		} else if oldValue != newValue {
+			result = append(result, SettingDiff{Name: name, Type: DiffChanged, OldValue: oldValue, NewValue: newValue})
+		}
+	}
+	for name, newValue := range b {
+		if _, ok := a[name]; !ok {
+			result = append(result, SettingDiff{Name: name, Type: DiffAdded, NewValue: newValue})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// IntSetting is a setting whose value is an integer.
+type IntSetting struct {
+	Setting
+}
+
+// NewIntSetting will create and store a new server setting holding an integer.
+func NewIntSetting(name string, def int) IntSetting {
+	return IntSetting{Setting: NewSetting(name, strconv.Itoa(def)).WithKind(KindInt)}
+}
+
+// Value will return the currently stored value of the setting, see GetInt.
+func (s IntSetting) Value() int {
+	return s.GetInt()
+}
+
+// SetValue will store the given value for the setting.
+func (s IntSetting) SetValue(value int) error {
+	return s.Set(strconv.Itoa(value))
+}
+
+// BoolSetting is a setting whose value is a boolean.
+type BoolSetting struct {
+	Setting
+}
+
+// NewBoolSetting will create and store a new server setting holding a boolean.
+func NewBoolSetting(name string, def bool) BoolSetting {
+	return BoolSetting{Setting: NewSetting(name, strconv.FormatBool(def)).WithKind(KindBool)}
+}
+
+// Value will return the currently stored value of the setting, see GetBool.
+func (s BoolSetting) Value() bool {
+	return s.GetBool()
+}
+
+// SetValue will store the given value for the setting.
+func (s BoolSetting) SetValue(value bool) error {
+	return s.Set(strconv.FormatBool(value))
+}
+
+// NewDeprecatedSetting will create an alias for a setting that was renamed from oldName to newName.
+// Reading or writing the alias forwards to the renamed setting and logs a deprecation warning once.
+func NewDeprecatedSetting(oldName, newName string) Setting {
+	settingsLock.Lock()
+	defer settingsLock.Unlock()
+	deprecatedSettings[oldName] = newName
+	return Setting{
+		Name:    oldName,
+		Default: settings[newName].defaultValue,
+	}
+}
+
+// resolve returns the setting that stores the value, which is the renamed setting for a deprecated alias.
+func (s Setting) resolve() Setting {
+	settingsLock.RLock()
+	newName, ok := deprecatedSettings[s.Name]
+	settingsLock.RUnlock()
+	if !ok {
+		return s
+	}
+	if _, warned := deprecationWarnings.LoadOrStore(s.Name, true); !warned {
+		logrus.Warnf("setting %s is deprecated, use %s instead", s.Name, newName)
+	}
+	if target, ok := ByName(newName); ok {
+		return target
+	}
+	return Setting{Name: newName}
+}
+
+// EnvKey will return the environment variable that overrides the setting with the given name. Dashes are
+// replaced with underscores, the result is uppercased and prefixed with CATTLE_, so system-default-registry
+// maps to CATTLE_SYSTEM_DEFAULT_REGISTRY.
+func EnvKey(name string) string {
+	return "CATTLE_" + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// GetEnvKey will return the given string formatted as a rancher environmental variable, see EnvKey.
 func GetEnvKey(key string) string {
-	return "CATTLE_" + strings.ToUpper(strings.Replace(key, "-", "_", -1))
+	return EnvKey(key)
 }
 
 func getMetadataConfig() string {
@@ -345,8 +1374,13 @@ func getMetadataConfig() string {
 
 // GetSettingByID returns a setting that is stored with the given id.
 func GetSettingByID(id string) string {
+	id = Setting{Name: id}.resolve().Name
+	if value := os.Getenv(GetEnvKey(id)); value != "" {
+		return value
+	}
+	provider := currentProvider()
 	if provider == nil {
-		s := settings[id]
+		s, _ := ByName(id)
 		return s.Default
 	}
 	return provider.Get(id)
@@ -385,6 +1419,33 @@ func GetRancherVersion() string {
 	return strings.TrimPrefix(rancherVersion, "v")
 }
 
+// CompareRancherVersion compares the running server version with the given version and returns -1, 0 or +1
+// when the server version is respectively older than, equal to or newer than it.
+// Development builds are newer than any released version. A version that can't be parsed is treated as 0.0.0.
+func CompareRancherVersion(other string) int {
+	otherVersion, err := semver.NewVersion(other)
+	if err != nil {
+		logrus.Errorf("failed to parse rancher version %s: %v", other, err)
+		otherVersion = &semver.Version{}
+	}
+
+	rancherVersion := GetRancherVersion()
+	if rancherVersion == RancherVersionDev {
+		return 1
+	}
+	current, err := semver.NewVersion(rancherVersion)
+	if err != nil {
+		// versions that are not semver are not releases, so they are handled like development builds
+		return 1
+	}
+	return current.Compare(otherVersion)
+}
+
+// AtLeastRancherVersion returns true if the running server version is equal to or newer than the given version.
+func AtLeastRancherVersion(version string) bool {
+	return CompareRancherVersion(version) >= 0
+}
+
 // IterateWhitelistedEnvVars iterates over the environment variables whitelisted
 // by CATTLE_WHITELIST_ENVVARS. If a variable is whitelisted but unset or empty,
 // the handler function will not be called for it.
@@ -1,24 +1,34 @@
 package settings
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestIsRelease(t *testing.T) {
 	inputs := map[string]bool{
-		"dev":         false,
-		"master-head": false,
-		"master":      false,
-		"v2.5.2":      true,
-		"v2":          true,
-		"v2.0":        true,
-		"v2.x":        true,
-		"v2.5-head":   false,
-		"2.5":         false,
-		"2.5-head":    false,
+		"dev":          false,
+		"master-head":  false,
+		"master":       false,
+		"v2.5.2":       true,
+		"v2":           true,
+		"v2.0":         true,
+		"v2.x":         true,
+		"v2.5-head":    false,
+		"2.5":          false,
+		"2.5-head":     false,
+		"v2.7.0-rc1":   false,
+		"v2.7.0-alpha": false,
+		"v2.7.0+meta":  true,
 	}
 	a := assert.New(t)
 	for key, value := range inputs {
@@ -29,3 +39,1043 @@ func TestIsRelease(t *testing.T) {
 		a.Equal(value, result, fmt.Sprintf("Expected value [%t] for key [%s]. Got value [%t]", value, key, result))
 	}
 }
+
+func TestGetBool(t *testing.T) {
+	inputs := map[string]bool{
+		"":        false,
+		"garbage": false,
+		"true":    true,
+		"TRUE":    true,
+		"1":       true,
+		"yes":     true,
+		"On":      true,
+		"false":   false,
+		"0":       false,
+	}
+	a := assert.New(t)
+	setting := NewSetting("test-get-bool", "")
+	for key, value := range inputs {
+		if err := setting.Set(key); err != nil {
+			t.Errorf("Encountered error while setting temp value: %v\n", err)
+		}
+		result := setting.GetBool()
+		a.Equal(value, result, fmt.Sprintf("Expected value [%t] for key [%s]. Got value [%t]", value, key, result))
+	}
+
+	defaultTrue := NewSetting("test-get-bool-default", "true")
+	if err := defaultTrue.Set("garbage"); err != nil {
+		t.Errorf("Encountered error while setting temp value: %v\n", err)
+	}
+	a.True(defaultTrue.GetBool(), "Expected unparseable value to fall back to the default")
+}
+
+func TestGetDuration(t *testing.T) {
+	inputs := map[string]time.Duration{
+		"":        15 * time.Minute,
+		"garbage": 15 * time.Minute,
+		"6h":      6 * time.Hour,
+		"1m30s":   90 * time.Second,
+		"300":     300 * time.Second,
+		"0":       0,
+	}
+	a := assert.New(t)
+	setting := NewSetting("test-get-duration", "900")
+	for key, value := range inputs {
+		if err := setting.Set(key); err != nil {
+			t.Errorf("Encountered error while setting temp value: %v\n", err)
+		}
+		result := setting.GetDuration()
+		a.Equal(value, result, fmt.Sprintf("Expected value [%s] for key [%s]. Got value [%s]", value, key, result))
+	}
+}
+
+func TestGetSeconds(t *testing.T) {
+	inputs := map[string]int{
+		"":        900,
+		"garbage": 900,
+		"-5":      900,
+		"21600":   21600,
+		"0":       0,
+	}
+	a := assert.New(t)
+	setting := NewSetting("test-get-seconds", SystemFeatureChartRefreshSeconds.Default)
+	for key, value := range inputs {
+		if err := setting.Set(key); err != nil {
+			t.Errorf("Encountered error while setting temp value: %v\n", err)
+		}
+		result := setting.GetSeconds()
+		a.Equal(value, result, fmt.Sprintf("Expected value [%d] for key [%s]. Got value [%d]", value, key, result))
+	}
+}
+
+func TestGetIntOr(t *testing.T) {
+	inputs := map[string]int{
+		"":        42,
+		"garbage": 42,
+		"1.5":     42,
+		"7":       7,
+		"-3":      -3,
+		"0":       0,
+	}
+	a := assert.New(t)
+	setting := NewSetting("test-get-int-or", "10")
+	for key, value := range inputs {
+		if err := setting.Set(key); err != nil {
+			t.Errorf("Encountered error while setting temp value: %v\n", err)
+		}
+		result := setting.GetIntOr(42)
+		a.Equal(value, result, fmt.Sprintf("Expected value [%d] for key [%s]. Got value [%d]", value, key, result))
+	}
+}
+
+func TestGetInt64(t *testing.T) {
+	inputs := map[string]int64{
+		"":                    4294967296,
+		"garbage":             4294967296,
+		"2147483648":          math.MaxInt32 + 1,
+		"-2147483649":         math.MinInt32 - 1,
+		"9223372036854775807": math.MaxInt64,
+		"9223372036854775808": 4294967296,
+		"0":                   0,
+	}
+	a := assert.New(t)
+	setting := NewSetting("test-get-int64", "4294967296")
+	for key, value := range inputs {
+		if err := setting.Set(key); err != nil {
+			t.Errorf("Encountered error while setting temp value: %v\n", err)
+		}
+		result := setting.GetInt64()
+		a.Equal(value, result, fmt.Sprintf("Expected value [%d] for key [%s]. Got value [%d]", value, key, result))
+	}
+}
+
+func TestGetFloat(t *testing.T) {
+	inputs := map[string]float64{
+		"":        1.5,
+		"garbage": 1.5,
+		"2":       2,
+		"0.25":    0.25,
+		"-3.75":   -3.75,
+	}
+	a := assert.New(t)
+	setting := NewSetting("test-get-float", "1.5")
+	for key, value := range inputs {
+		if err := setting.Set(key); err != nil {
+			t.Errorf("Encountered error while setting temp value: %v\n", err)
+		}
+		result := setting.GetFloat()
+		a.Equal(value, result, fmt.Sprintf("Expected value [%f] for key [%s]. Got value [%f]", value, key, result))
+	}
+}
+
+func TestGetSlice(t *testing.T) {
+	inputs := map[string][]string{
+		"":              {"a", "b"},
+		"x":             {"x"},
+		"x,y":           {"x", "y"},
+		" x , y ":       {"x", "y"},
+		"x,y,":          {"x", "y"},
+		",x,,y, ,":      {"x", "y"},
+		"x y,z":         {"x y", "z"},
+		"registry.io/a": {"registry.io/a"},
+	}
+	a := assert.New(t)
+	setting := NewSetting("test-get-slice", "a, b,")
+	for key, value := range inputs {
+		if err := setting.Set(key); err != nil {
+			t.Errorf("Encountered error while setting temp value: %v\n", err)
+		}
+		result := setting.GetSlice()
+		a.Equal(value, result, fmt.Sprintf("Expected value %v for key [%s]. Got value %v", value, key, result))
+	}
+}
+
+func TestGetIntSlice(t *testing.T) {
+	inputs := map[string][]int{
+		"":                  {80, 443},
+		"8080":              {8080},
+		"22, 80 ,443":       {22, 80, 443},
+		"22,http,443,":      {22, 443},
+		"-1,0,1.5,6443":     {-1, 0, 6443},
+		"garbage":           nil,
+		"9000,,9001, ,9002": {9000, 9001, 9002},
+	}
+	a := assert.New(t)
+	setting := NewSetting("test-get-int-slice", "80,443")
+	for key, value := range inputs {
+		if err := setting.Set(key); err != nil {
+			t.Errorf("Encountered error while setting temp value: %v\n", err)
+		}
+		result := setting.GetIntSlice()
+		a.Equal(value, result, fmt.Sprintf("Expected value %v for key [%s]. Got value %v", value, key, result))
+	}
+}
+
+func TestGetSliceMerged(t *testing.T) {
+	inputs := map[string][]string{
+		"":        {"a", "b"},
+		"c":       {"a", "b", "c"},
+		"b,c":     {"a", "b", "c"},
+		"c,a,c,d": {"a", "b", "c", "d"},
+		" d , c ": {"a", "b", "d", "c"},
+	}
+	a := assert.New(t)
+	setting := NewSetting("test-get-slice-merged", "a, b,")
+	for key, value := range inputs {
+		if err := setting.Set(key); err != nil {
+			t.Errorf("Encountered error while setting temp value: %v\n", err)
+		}
+		result := setting.GetSliceMerged()
+		a.Equal(value, result, fmt.Sprintf("Expected value %v for key [%s]. Got value %v", value, key, result))
+	}
+}
+
+func TestWithValidator(t *testing.T) {
+	a := assert.New(t)
+	setting := NewSetting("test-with-validator", "default").WithValidator(func(value string) error {
+		if value == "invalid" {
+			return fmt.Errorf("value must not be invalid")
+		}
+		return nil
+	})
+
+	a.NoError(setting.Set("valid"))
+	a.Equal("valid", setting.Get())
+
+	a.Error(setting.Set("invalid"))
+	a.Equal("valid", setting.Get(), "Expected rejected value to not be stored")
+
+	unvalidated := NewSetting("test-without-validator", "default")
+	a.NoError(unvalidated.Set("invalid"))
+	a.Equal("invalid", unvalidated.Get())
+}
+
+func TestServerURLValidator(t *testing.T) {
+	inputs := map[string]bool{
+		"":                            true,
+		"https://rancher.example.com": true,
+		"https://10.0.0.1:8443":       true,
+		"http://foo":                  false,
+		"not-a-url":                   false,
+		"https://":                    false,
+	}
+	a := assert.New(t)
+	for key, valid := range inputs {
+		err := ServerURL.Validate(key)
+		a.Equal(valid, err == nil, fmt.Sprintf("Expected valid [%t] for key [%s]. Got error [%v]", valid, key, err))
+	}
+}
+
+func TestFullShellImage(t *testing.T) {
+	const digest = "sha256:4b58c5b1a8f1b5d2f7c0d3e1a2b4c6d8e0f1a3b5c7d9e1f2a4b6c8d0e2f4a6b8"
+	tests := []struct {
+		image    string
+		registry string
+		digest   string
+		want     string
+	}{
+		{image: "rancher/shell:v0.1.20", registry: "", want: "rancher/shell:v0.1.20"},
+		{image: "rancher/shell:v0.1.20", registry: "mirror.example.com", want: "mirror.example.com/rancher/shell:v0.1.20"},
+		{image: "docker.io/rancher/shell:v0.1.20", registry: "", want: "docker.io/rancher/shell:v0.1.20"},
+		{image: "docker.io/rancher/shell:v0.1.20", registry: "mirror.example.com", want: "mirror.example.com/rancher/shell:v0.1.20"},
+		{image: "registry.local:5000/rancher/shell:v0.1.20", registry: "mirror.example.com", want: "mirror.example.com/rancher/shell:v0.1.20"},
+		{image: "localhost/rancher/shell:v0.1.20", registry: "mirror.example.com", want: "mirror.example.com/rancher/shell:v0.1.20"},
+		{image: "rancher/shell:v0.1.20", registry: "", digest: digest, want: "rancher/shell@" + digest},
+		{image: "rancher/shell", registry: "", digest: digest, want: "rancher/shell@" + digest},
+		{image: "registry.local:5000/rancher/shell", registry: "", digest: digest, want: "registry.local:5000/rancher/shell@" + digest},
+		{image: "registry.local:5000/rancher/shell:v0.1.20", registry: "mirror.example.com", digest: digest, want: "mirror.example.com/rancher/shell@" + digest},
+		{image: "rancher/shell:v0.1.20@sha256:0000", registry: "", digest: digest, want: "rancher/shell@" + digest},
+	}
+	a := assert.New(t)
+	defer func(image, registry, digest string) {
+		_ = ShellImage.Set(image)
+		_ = SystemDefaultRegistry.setInternal(registry)
+		_ = ShellImageDigest.Set(digest)
+	}(ShellImage.Get(), SystemDefaultRegistry.Get(), ShellImageDigest.Get())
+
+	for _, tt := range tests {
+		if err := ShellImage.Set(tt.image); err != nil {
+			t.Errorf("Encountered error while setting temp image: %v\n", err)
+		}
+		if err := SystemDefaultRegistry.setInternal(tt.registry); err != nil {
+			t.Errorf("Encountered error while setting temp registry: %v\n", err)
+		}
+		if err := ShellImageDigest.Set(tt.digest); err != nil {
+			t.Errorf("Encountered error while setting temp digest: %v\n", err)
+		}
+		a.Equal(tt.want, FullShellImage(), fmt.Sprintf("Unexpected image for [%s] with registry [%s] and digest [%s]", tt.image, tt.registry, tt.digest))
+	}
+}
+
+func TestResolveImage(t *testing.T) {
+	tests := []struct {
+		image    string
+		registry string
+		want     string
+	}{
+		{image: "rancher/rancher-agent:v2.6-head", registry: "", want: "rancher/rancher-agent:v2.6-head"},
+		{image: "rancher/rancher-agent:v2.6-head", registry: "mirror.example.com", want: "mirror.example.com/rancher/rancher-agent:v2.6-head"},
+		{image: "quay.io/rancher/rancher-agent:v2.6-head", registry: "mirror.example.com", want: "mirror.example.com/rancher/rancher-agent:v2.6-head"},
+		{image: "busybox", registry: "mirror.example.com", want: "mirror.example.com/busybox"},
+	}
+	a := assert.New(t)
+	defer func(registry string) {
+		_ = SystemDefaultRegistry.setInternal(registry)
+	}(SystemDefaultRegistry.Get())
+
+	for _, tt := range tests {
+		if err := SystemDefaultRegistry.setInternal(tt.registry); err != nil {
+			t.Errorf("Encountered error while setting temp registry: %v\n", err)
+		}
+		a.Equal(tt.want, ResolveImage(tt.image), fmt.Sprintf("Unexpected image for [%s] with registry [%s]", tt.image, tt.registry))
+	}
+}
+
+func TestOnChange(t *testing.T) {
+	type change struct {
+		oldValue string
+		newValue string
+	}
+	a := assert.New(t)
+	setting := NewSetting("test-on-change", "initial")
+
+	var first, second []change
+	setting.OnChange(func(oldValue, newValue string) {
+		first = append(first, change{oldValue, newValue})
+	})
+	setting.OnChange(func(oldValue, newValue string) {
+		panic("observer failure")
+	})
+	setting.OnChange(func(oldValue, newValue string) {
+		second = append(second, change{oldValue, newValue})
+	})
+
+	a.NoError(setting.Set("updated"))
+	a.NoError(setting.Set("updated"))
+
+	expected := []change{{"initial", "updated"}}
+	a.Equal(expected, first)
+	a.Equal(expected, second)
+	a.Equal("updated", setting.Get())
+}
+
+func TestWatch(t *testing.T) {
+	a := assert.New(t)
+	setting := NewSetting("test-watch", "initial")
+
+	watch := setting.Watch()
+	a.NoError(setting.Set("updated"))
+	select {
+	case value := <-watch:
+		a.Equal("updated", value)
+	case <-time.After(time.Second):
+		t.Fatal("Expected a change to be delivered")
+	}
+
+	a.NoError(setting.Set("first"))
+	a.NoError(setting.Set("second"))
+	a.Equal("second", <-watch, "Expected only the latest value to be kept")
+
+	setting.StopWatch(watch)
+	a.NoError(setting.Set("stopped"))
+	value, ok := <-watch
+	a.False(ok, fmt.Sprintf("Expected the channel to be closed but got value [%s]", value))
+	setting.StopWatch(watch)
+}
+
+type fakeProvider struct {
+	values map[string]string
+}
+
+func (f *fakeProvider) Get(name string) string {
+	if value := os.Getenv(GetEnvKey(name)); value != "" {
+		return value
+	}
+	if value, ok := f.values[name]; ok {
+		return value
+	}
+	return settings[name].Default
+}
+
+func (f *fakeProvider) Set(name, value string) error {
+	f.values[name] = value
+	return nil
+}
+
+func (f *fakeProvider) SetIfUnset(name, value string) error {
+	if _, ok := f.values[name]; !ok {
+		f.values[name] = value
+	}
+	return nil
+}
+
+func (f *fakeProvider) SetAll(map[string]Setting) error {
+	return nil
+}
+
+func TestLoadDefaults(t *testing.T) {
+	a := assert.New(t)
+	fromJSON := NewSetting("test-load-defaults-json", "default")
+	fromYAML := NewSetting("test-load-defaults-yaml", "default")
+	custom := NewSetting("test-load-defaults-custom", "default")
+	a.NoError(custom.Set("custom"))
+
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "defaults.json")
+	a.NoError(os.WriteFile(jsonPath, []byte(`{"test-load-defaults-json": "from-json", "test-load-defaults-custom": "from-json"}`), 0600))
+	yamlPath := filepath.Join(dir, "defaults.yaml")
+	a.NoError(os.WriteFile(yamlPath, []byte("test-load-defaults-yaml: from-yaml\nunknown-setting: ignored\n"), 0600))
+
+	a.NoError(LoadDefaults(jsonPath))
+	a.NoError(LoadDefaults(yamlPath))
+	a.Equal("from-json", fromJSON.Get())
+	a.Equal(SourceInjected, fromJSON.Source())
+	a.Equal("from-yaml", fromYAML.Get())
+	a.Equal("custom", custom.Get(), "Expected a value stored with Set to win over a loaded default")
+	value, err := custom.Reset()
+	a.NoError(err)
+	a.Equal("from-json", value)
+
+	a.Error(LoadDefaults(filepath.Join(dir, "missing.json")))
+	invalidPath := filepath.Join(dir, "invalid.yaml")
+	a.NoError(os.WriteFile(invalidPath, []byte("- not\n- a map\n"), 0600))
+	a.Error(LoadDefaults(invalidPath))
+}
+
+func TestSource(t *testing.T) {
+	a := assert.New(t)
+
+	declared := NewSetting("test-source-default", "default")
+	a.Equal(SourceDefault, declared.Source())
+
+	injected := NewSetting("test-source-injected", "default")
+	injectDefaults(`{"test-source-injected": "injected"}`)
+	a.Equal("injected", injected.Get())
+	a.Equal(SourceInjected, injected.Source())
+
+	custom := NewSetting("test-source-custom", "default")
+	a.NoError(custom.Set("custom"))
+	a.Equal(SourceCustom, custom.Source())
+
+	defer func() { provider = nil }()
+	a.NoError(SetProvider(&fakeProvider{values: map[string]string{}}))
+	a.Equal(SourceDefault, declared.Source())
+	a.Equal(SourceInjected, injected.Source())
+
+	a.NoError(declared.Set("changed"))
+	a.Equal(SourceCustom, declared.Source())
+
+	t.Setenv(GetEnvKey(declared.Name), "from-env")
+	a.Equal("from-env", declared.Get())
+	a.Equal(SourceEnv, declared.Source())
+}
+
+func TestReset(t *testing.T) {
+	a := assert.New(t)
+	defaultImage := ShellImage.Get()
+	a.NoError(ShellImage.Set("custom/shell:v1"))
+	a.Equal("custom/shell:v1", ShellImage.Get())
+	a.Equal(SourceCustom, ShellImage.Source())
+
+	value, err := ShellImage.Reset()
+	a.NoError(err)
+	a.Equal(defaultImage, value)
+	a.Equal(defaultImage, ShellImage.Get())
+	a.Equal(SourceDefault, ShellImage.Source())
+}
+
+func TestGetJSON(t *testing.T) {
+	type banner struct {
+		Text  string `json:"text"`
+		Color string `json:"color"`
+	}
+	a := assert.New(t)
+	setting := NewSetting("test-get-json", `{"text": "default", "color": "red"}`)
+
+	var fromDefault banner
+	a.NoError(setting.Set(""))
+	a.NoError(setting.GetJSON(&fromDefault))
+	a.Equal(banner{Text: "default", Color: "red"}, fromDefault)
+
+	var fromValue map[string]string
+	a.NoError(setting.Set(`{"text": "custom"}`))
+	a.NoError(setting.GetJSON(&fromValue))
+	a.Equal(map[string]string{"text": "custom"}, fromValue)
+
+	var malformed banner
+	a.NoError(setting.Set(`{"text": `))
+	a.Error(setting.GetJSON(&malformed))
+}
+
+func TestExport(t *testing.T) {
+	a := assert.New(t)
+	unchanged := NewSetting("test-export-unchanged", "default")
+	changed := NewSetting("test-export-changed", "default")
+	a.NoError(changed.Set("custom"))
+
+	find := func(values []SettingValue, name string) (SettingValue, bool) {
+		for _, v := range values {
+			if v.Name == name {
+				return v, true
+			}
+		}
+		return SettingValue{}, false
+	}
+
+	custom := Export(false)
+	_, ok := find(custom, unchanged.Name)
+	a.False(ok, "Expected setting at its default to be excluded")
+	value, ok := find(custom, changed.Name)
+	a.True(ok, "Expected changed setting to be exported")
+	a.Equal(SettingValue{Name: changed.Name, Value: "custom", Default: "default", Source: SourceCustom}, value)
+
+	all := Export(true)
+	value, ok = find(all, unchanged.Name)
+	a.True(ok, "Expected setting at its default to be exported with all")
+	a.Equal(SettingValue{Name: unchanged.Name, Value: "default", Default: "default", Source: SourceDefault}, value)
+	a.True(sort.SliceIsSorted(all, func(i, j int) bool { return all[i].Name < all[j].Name }))
+}
+
+func TestGetRaw(t *testing.T) {
+	a := assert.New(t)
+	setting := NewSetting("test-get-raw", "default")
+
+	value, isSet := setting.GetRaw()
+	a.False(isSet, "Expected never set setting to not be set")
+	a.Equal("", value)
+
+	a.NoError(setting.Set(""))
+	value, isSet = setting.GetRaw()
+	a.True(isSet, "Expected setting set to empty to be set")
+	a.Equal("", value)
+
+	a.NoError(setting.Set("custom"))
+	value, isSet = setting.GetRaw()
+	a.True(isSet)
+	a.Equal("custom", value)
+
+	_, err := setting.Reset()
+	a.NoError(err)
+	_, isSet = setting.GetRaw()
+	a.False(isSet, "Expected reset setting to not be set")
+}
+
+func TestApply(t *testing.T) {
+	a := assert.New(t)
+	first := NewSetting("test-apply-first", "default")
+	second := NewSetting("test-apply-second", "default").WithValidator(func(value string) error {
+		if value == "invalid" {
+			return fmt.Errorf("value must not be invalid")
+		}
+		return nil
+	})
+	third := NewSetting("test-apply-third", "default")
+
+	err := Apply(map[string]string{
+		first.Name:             "applied",
+		second.Name:            "invalid",
+		third.Name:             "applied",
+		"test-apply-not-found": "value",
+	}, false)
+	a.Error(err)
+	a.Contains(err.Error(), second.Name)
+	a.Contains(err.Error(), "test-apply-not-found")
+	a.Equal("applied", first.Get())
+	a.Equal("default", second.Get())
+	a.Equal("applied", third.Get())
+
+	a.NoError(Apply(map[string]string{first.Name: "again", "test-apply-not-found": "value"}, true))
+	a.Equal("again", first.Get())
+}
+
+func TestTypedSettings(t *testing.T) {
+	a := assert.New(t)
+
+	intSetting := NewIntSetting("test-int-setting", 900)
+	a.Equal("900", intSetting.Default)
+	a.Equal(900, intSetting.Value())
+	a.NoError(intSetting.SetValue(60))
+	a.Equal(60, intSetting.Value())
+	a.Equal("60", intSetting.Get())
+
+	boolSetting := NewBoolSetting("test-bool-setting", true)
+	a.Equal("true", boolSetting.Default)
+	a.True(boolSetting.Value())
+	a.NoError(boolSetting.SetValue(false))
+	a.False(boolSetting.Value())
+	a.Equal("false", boolSetting.Get())
+}
+
+func TestGetIntClamped(t *testing.T) {
+	inputs := map[string]int{
+		"-5":      10,
+		"0":       10,
+		"10":      10,
+		"300":     300,
+		"3600":    3600,
+		"86400":   3600,
+		"garbage": 900,
+	}
+	a := assert.New(t)
+	setting := NewSetting("test-get-int-clamped", "900")
+	for key, value := range inputs {
+		if err := setting.Set(key); err != nil {
+			t.Errorf("Encountered error while setting temp value: %v\n", err)
+		}
+		result := setting.GetIntClamped(10, 3600)
+		a.Equal(value, result, fmt.Sprintf("Expected value [%d] for key [%s]. Got value [%d]", value, key, result))
+	}
+}
+
+func TestGetEnvOverride(t *testing.T) {
+	a := assert.New(t)
+	setting := NewSetting("test-env-override", "default")
+	a.Equal("default", setting.Get())
+
+	t.Setenv("CATTLE_TEST_ENV_OVERRIDE", "from-env")
+	a.Equal("from-env", setting.Get())
+	a.Equal("from-env", GetSettingByID(setting.Name))
+	a.Equal(SourceEnv, setting.Source())
+
+	a.NoError(setting.Set("custom"))
+	a.Equal("from-env", setting.Get(), "Expected the environment variable to take precedence over stored values")
+}
+
+func TestCompareRancherVersion(t *testing.T) {
+	tests := []struct {
+		serverVersion string
+		other         string
+		want          int
+	}{
+		{serverVersion: "v2.6.9", other: "2.7.0", want: -1},
+		{serverVersion: "v2.6.9", other: "v2.6.9", want: 0},
+		{serverVersion: "v2.7.1", other: "2.6.9", want: 1},
+		{serverVersion: "v2.7.0", other: "2.7", want: 0},
+		{serverVersion: "v2.7.0-rc1", other: "2.7.0", want: -1},
+		{serverVersion: "dev", other: "2.7.0", want: 1},
+		{serverVersion: "v2.7-head", other: "99.0.0", want: 1},
+	}
+	a := assert.New(t)
+	defer func(version string) { _ = ServerVersion.Set(version) }(ServerVersion.Get())
+
+	for _, tt := range tests {
+		if err := ServerVersion.Set(tt.serverVersion); err != nil {
+			t.Errorf("Encountered error while setting temp version: %v\n", err)
+		}
+		result := CompareRancherVersion(tt.other)
+		a.Equal(tt.want, result, fmt.Sprintf("Expected [%d] comparing server [%s] to [%s]. Got [%d]", tt.want, tt.serverVersion, tt.other, result))
+		a.Equal(tt.want >= 0, AtLeastRancherVersion(tt.other))
+	}
+}
+
+func TestGetMap(t *testing.T) {
+	inputs := map[string]map[string]string{
+		"":                   {"a": "1"},
+		"x=1":                {"x": "1"},
+		" x = 1 , y=2,":      {"x": "1", "y": "2"},
+		"x=1,malformed,y=2":  {"x": "1", "y": "2"},
+		"x=,=2":              {"x": ""},
+		"url=https://a.b?c=": {"url": "https://a.b?c="},
+	}
+	a := assert.New(t)
+	setting := NewSetting("test-get-map", "a=1")
+	for key, value := range inputs {
+		if err := setting.Set(key); err != nil {
+			t.Errorf("Encountered error while setting temp value: %v\n", err)
+		}
+		result := setting.GetMap()
+		a.Equal(value, result, fmt.Sprintf("Expected value %v for key [%s]. Got value %v", value, key, result))
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	a := assert.New(t)
+	setting := NewSetting("test-read-only", "default").AsReadOnly()
+
+	a.Error(setting.Set("changed"))
+	a.Error(setting.SetIfUnset("changed"))
+	_, err := setting.Reset()
+	a.Error(err)
+	a.Equal("default", setting.Get())
+
+	a.NoError(setting.setInternal("internal"))
+	a.Equal("internal", setting.Get())
+
+	injectDefaults(`{"test-read-only": "injected"}`)
+	a.Equal("injected", setting.Get())
+}
+
+func TestDeprecatedSetting(t *testing.T) {
+	a := assert.New(t)
+	renamed := NewSetting("test-renamed", "default")
+	deprecated := NewDeprecatedSetting("test-deprecated", renamed.Name)
+
+	a.Equal("default", deprecated.Get())
+	a.Equal("default", deprecated.Default)
+
+	a.NoError(renamed.Set("from-new"))
+	a.Equal("from-new", deprecated.Get())
+	a.Equal("from-new", GetSettingByID(deprecated.Name))
+
+	a.NoError(deprecated.Set("from-old"))
+	a.Equal("from-old", renamed.Get())
+	a.Equal(SourceCustom, deprecated.Source())
+}
+
+func TestAll(t *testing.T) {
+	a := assert.New(t)
+	first := NewSetting("test-all-first", "first-default")
+	second := NewSetting("test-all-second", "second-default")
+
+	found := map[string]string{}
+	for _, s := range All() {
+		found[s.Name] = s.Default
+	}
+	a.Equal(first.Default, found[first.Name])
+	a.Equal(second.Default, found[second.Name])
+
+	s, ok := ByName(second.Name)
+	a.True(ok)
+	a.Equal(second.Name, s.Name)
+	_, ok = ByName("test-all-missing")
+	a.False(ok)
+}
+
+type auditRecord struct {
+	name, oldValue, newValue string
+	changedAt                time.Time
+}
+
+type fakeAuditSink struct {
+	records []auditRecord
+}
+
+func (f *fakeAuditSink) Record(name, oldValue, newValue string, changedAt time.Time) {
+	f.records = append(f.records, auditRecord{name: name, oldValue: oldValue, newValue: newValue, changedAt: changedAt})
+}
+
+func TestAuditSink(t *testing.T) {
+	a := assert.New(t)
+	sink := &fakeAuditSink{}
+	SetAuditSink(sink)
+	defer SetAuditSink(nil)
+
+	setting := NewSetting("test-audit-sink", "old")
+	before := time.Now()
+	a.NoError(setting.Set("new"))
+	_, err := setting.Reset()
+	a.NoError(err)
+
+	if a.Len(sink.records, 2) {
+		a.Equal("test-audit-sink", sink.records[0].name)
+		a.Equal("old", sink.records[0].oldValue)
+		a.Equal("new", sink.records[0].newValue)
+		a.False(sink.records[0].changedAt.Before(before))
+		a.Equal("new", sink.records[1].oldValue)
+		a.Equal("old", sink.records[1].newValue)
+	}
+}
+
+func TestEnvKey(t *testing.T) {
+	a := assert.New(t)
+	inputs := map[string]string{
+		"system-default-registry": "CATTLE_SYSTEM_DEFAULT_REGISTRY",
+		"server-url":              "CATTLE_SERVER_URL",
+		"ui-index":                "CATTLE_UI_INDEX",
+		"telemetry-opt":           "CATTLE_TELEMETRY_OPT",
+	}
+	for key, value := range inputs {
+		envKey := EnvKey(key)
+		a.Equal(value, envKey, fmt.Sprintf("Expected value [%s] for key [%s] but got [%s]", value, key, envKey))
+		a.Equal(envKey, GetEnvKey(key))
+	}
+}
+
+func TestMustGet(t *testing.T) {
+	a := assert.New(t)
+	empty := NewSetting("test-must-get-empty", "")
+	a.Panics(func() { empty.MustGet() })
+	a.Panics(func() { empty.MustGetInt() })
+
+	a.NoError(empty.Set("value"))
+	a.Equal("value", empty.MustGet())
+	a.Panics(func() { empty.MustGetInt() }, "Expected a value that is not an integer to panic")
+
+	withDefault := NewSetting("test-must-get-default", "10")
+	a.Equal("10", withDefault.MustGet())
+	a.Equal(10, withDefault.MustGetInt())
+	a.NoError(withDefault.Set("garbage"))
+	a.Equal(10, withDefault.MustGetInt(), "Expected an invalid value to fall back to the default")
+}
+
+func TestRegisterStruct(t *testing.T) {
+	a := assert.New(t)
+	config := struct {
+		Registry Setting `setting:"test-register-struct-registry,docker.io"`
+		Images   Setting `setting:"test-register-struct-images,a,b"`
+		Empty    string  `setting:"test-register-struct-empty"`
+		Ignored  Setting
+	}{}
+
+	registered := RegisterStruct(&config)
+	a.Len(registered, 3)
+	expected := map[string][2]string{
+		"Registry": {"test-register-struct-registry", "docker.io"},
+		"Images":   {"test-register-struct-images", "a,b"},
+		"Empty":    {"test-register-struct-empty", ""},
+	}
+	for field, want := range expected {
+		s, ok := registered[field]
+		if !a.True(ok, fmt.Sprintf("Expected field [%s] to be registered", field)) {
+			continue
+		}
+		a.Equal(want[0], s.Name, fmt.Sprintf("Expected name [%s] for field [%s] but got [%s]", want[0], field, s.Name))
+		a.Equal(want[1], s.Get(), fmt.Sprintf("Expected default [%s] for field [%s] but got [%s]", want[1], field, s.Get()))
+		_, ok = ByName(want[0])
+		a.True(ok, fmt.Sprintf("Expected setting [%s] to be registered", want[0]))
+	}
+	a.Equal("test-register-struct-registry", config.Registry.Name)
+	a.Equal("docker.io", config.Registry.Get())
+	a.Empty(config.Ignored.Name)
+
+	a.Panics(func() { RegisterStruct("not a struct") })
+	a.Panics(func() {
+		RegisterStruct(struct {
+			Unnamed Setting `setting:",default"`
+		}{})
+	})
+}
+
+func TestMetadata(t *testing.T) {
+	tests := []struct {
+		setting Setting
+		kind    SettingKind
+		options []string
+	}{
+		{setting: ServerVersion, kind: KindString},
+		{setting: SystemFeatureChartRefreshSeconds, kind: KindInt},
+		{setting: SystemCatalog, kind: KindEnum, options: []string{"external", "bundled"}},
+		{setting: NewSetting("test-metadata-log-level", "info").WithKind(KindEnum, "debug", "info", "warn", "error"), kind: KindEnum, options: []string{"debug", "info", "warn", "error"}},
+		{setting: NewBoolSetting("test-metadata-bool", true).Setting, kind: KindBool},
+	}
+	a := assert.New(t)
+	for _, tt := range tests {
+		kind, options := tt.setting.Metadata()
+		a.Equal(tt.kind, kind, fmt.Sprintf("Unexpected kind for [%s]", tt.setting.Name))
+		a.Equal(tt.options, options, fmt.Sprintf("Unexpected options for [%s]", tt.setting.Name))
+	}
+}
+
+func TestGetURL(t *testing.T) {
+	a := assert.New(t)
+	setting := NewSetting("test-get-url", "https://default.example.com")
+
+	u, err := setting.GetURL()
+	a.NoError(err)
+	a.Equal("default.example.com", u.Host)
+
+	a.NoError(setting.Set("https://rancher.example.com:8443/dashboard"))
+	u, err = setting.GetURL()
+	a.NoError(err)
+	a.Equal("https", u.Scheme)
+	a.Equal("rancher.example.com:8443", u.Host)
+	a.Equal("/dashboard", u.Path)
+
+	a.NoError(setting.Set("http://[::1"))
+	_, err = setting.GetURL()
+	a.Error(err)
+}
+
+func TestChanged(t *testing.T) {
+	a := assert.New(t)
+	setting := NewSetting("test-changed", "default")
+	a.False(setting.Changed(), "Expected setting at its default to be unchanged")
+
+	a.NoError(setting.Set("default"))
+	a.False(setting.Changed(), "Expected setting explicitly set to its default to be unchanged")
+
+	a.NoError(setting.Set("custom"))
+	a.True(setting.Changed(), "Expected setting set to a different value to be changed")
+
+	t.Setenv(GetEnvKey(setting.Name), "default")
+	a.False(setting.Changed(), "Expected environment value equal to the default to be unchanged")
+}
+
+func TestDiff(t *testing.T) {
+	a := assert.New(t)
+	before := map[string]string{
+		"server-url":     "https://old.example.com",
+		"telemetry-opt":  "in",
+		"ui-pl":          "rancher",
+		"ui-performance": "",
+	}
+	after := map[string]string{
+		"server-url":              "https://new.example.com",
+		"system-default-registry": "mirror.example.com",
+		"ui-pl":                   "rancher",
+		"ui-performance":          "",
+	}
+
+	a.Equal([]SettingDiff{
+		{Name: "server-url", Type: DiffChanged, OldValue: "https://old.example.com", NewValue: "https://new.example.com"},
+		{Name: "system-default-registry", Type: DiffAdded, NewValue: "mirror.example.com"},
+		{Name: "telemetry-opt", Type: DiffRemoved, OldValue: "in"},
+	}, Diff(before, after))
+	a.Empty(Diff(before, before))
+}
+
+func TestIsFeatureEnabled(t *testing.T) {
+	a := assert.New(t)
+	defer func(value string) {
+		_ = DisableAllFeatures.Set(value)
+	}(DisableAllFeatures.Get())
+
+	enabled := NewSetting("test-feature-enabled", "true")
+	disabled := NewSetting("test-feature-disabled", "off")
+	a.True(enabled.IsFeatureEnabled())
+	a.False(disabled.IsFeatureEnabled())
+
+	a.NoError(DisableAllFeatures.Set("true"))
+	a.False(enabled.IsFeatureEnabled(), "Expected the kill switch to turn off enabled features")
+	a.False(disabled.IsFeatureEnabled())
+	a.True(DisableAllFeatures.IsFeatureEnabled(), "Expected the kill switch to not disable itself")
+
+	a.NoError(DisableAllFeatures.Set("false"))
+	t.Setenv(GetEnvKey(DisableAllFeatures.Name), "yes")
+	a.False(enabled.IsFeatureEnabled(), "Expected the kill switch environment variable to take precedence")
+}
+
+func TestSettingErrors(t *testing.T) {
+	a := assert.New(t)
+	errInvalid := fmt.Errorf("value must not be invalid")
+	validated := NewSetting("test-errors-validated", "default").WithValidator(func(value string) error {
+		if value == "invalid" {
+			return errInvalid
+		}
+		return nil
+	})
+	readOnly := NewSetting("test-errors-read-only", "default").AsReadOnly()
+
+	err := validated.Set("invalid")
+	a.True(errors.Is(err, ErrValidation), "Expected a validation error, got %v", err)
+	a.True(errors.Is(err, errInvalid), "Expected the validator error to be wrapped")
+	a.False(errors.Is(err, ErrReadOnly))
+	a.EqualError(err, "invalid value for setting test-errors-validated: value must not be invalid")
+
+	err = readOnly.Set("changed")
+	a.True(errors.Is(err, ErrReadOnly), "Expected a read-only error, got %v", err)
+	_, err = readOnly.Reset()
+	a.True(errors.Is(err, ErrReadOnly), "Expected a read-only error, got %v", err)
+
+	err = Apply(map[string]string{"test-errors-unknown": "value"}, false)
+	a.True(errors.Is(err, ErrUnknownSetting), "Expected an unknown setting error, got %v", err)
+
+	var settingErr *SettingError
+	if a.True(errors.As(err, &settingErr)) {
+		a.Equal("test-errors-unknown", settingErr.Name)
+	}
+}
+
+func TestConcurrentGetSet(t *testing.T) {
+	a := assert.New(t)
+	setting := NewSetting("test-concurrent", "0")
+	setting.OnChange(func(oldValue, newValue string) {})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				a.NoError(setting.Set(fmt.Sprintf("%d", i*100+j)))
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_ = setting.Get()
+				_ = setting.GetInt()
+				_ = setting.Source()
+				_, _ = setting.GetRaw()
+				_ = Export(true)
+			}
+		}()
+	}
+	wg.Wait()
+
+	_, err := setting.Reset()
+	a.NoError(err)
+	a.Equal("0", setting.Get())
+}
+
+func TestGetOneOf(t *testing.T) {
+	inputs := map[string]string{
+		"":      "info",
+		"debug": "debug",
+		"error": "error",
+		"loud":  "info",
+	}
+	a := assert.New(t)
+	setting := NewSetting("test-get-one-of", "info")
+	for key, value := range inputs {
+		if err := setting.Set(key); err != nil {
+			t.Errorf("Encountered error while setting temp value: %v\n", err)
+		}
+		result := setting.GetOneOf("debug", "info", "warn", "error")
+		a.Equal(value, result, fmt.Sprintf("Expected value [%s] for key [%s]. Got value [%s]", value, key, result))
+	}
+
+	a.NoError(setting.Set("loud"))
+	a.Equal("warn", setting.GetOneOf("warn", "error"), "Expected the first allowed value when the default isn't allowed")
+	a.Equal("", setting.GetOneOf())
+}
+
+func TestGetBytes(t *testing.T) {
+	inputs := map[string]int64{
+		"":        64 * 1024 * 1024,
+		"garbage": 64 * 1024 * 1024,
+		"1024":    1024,
+		"4Ki":     4 * 1024,
+		"512Mi":   512 * 1024 * 1024,
+		"2Gi":     2 * 1024 * 1024 * 1024,
+		"500M":    500 * 1000 * 1000,
+		"1.5G":    1500 * 1000 * 1000,
+	}
+	a := assert.New(t)
+	setting := NewSetting("test-get-bytes", "64Mi")
+	for key, value := range inputs {
+		if err := setting.Set(key); err != nil {
+			t.Errorf("Encountered error while setting temp value: %v\n", err)
+		}
+		result, err := setting.GetBytes()
+		a.NoError(err)
+		a.Equal(value, result, fmt.Sprintf("Expected value [%d] for key [%s]. Got value [%d]", value, key, result))
+	}
+
+	_, err := NewSetting("test-get-bytes-invalid", "garbage").GetBytes()
+	a.Error(err)
+}
+
+func TestLint(t *testing.T) {
+	a := assert.New(t)
+	count := NewIntSetting("test-lint-int", 2)
+	enabled := NewBoolSetting("test-lint-bool", true)
+	mode := NewSetting("test-lint-enum", "a").WithKind(KindEnum, "a", "b")
+	untyped := NewSetting("test-lint-string", "value")
+
+	a.NoError(untyped.Set("two"))
+	for _, problem := range Lint() {
+		a.NotContains(problem, "test-lint-", "Expected no problems for valid values")
+	}
+
+	a.NoError(count.Set("two"))
+	a.NoError(enabled.Set("maybe"))
+	a.NoError(mode.Set("c"))
+	problems := Lint()
+	a.Contains(problems, "setting test-lint-int=two is not an int")
+	a.Contains(problems, "setting test-lint-bool=maybe is not a bool")
+	a.Contains(problems, "setting test-lint-enum=c is not one of [a b]")
+	for _, problem := range problems {
+		a.NotContains(problem, untyped.Name)
+	}
+}